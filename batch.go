@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EachFunc is an operation run for each item by SupplyEach.
+type EachFunc[In, Out any] func(item In) (Out, error)
+
+// BatchFunc is an operation run by SupplyBatch. It receives the pending items and returns the subset still to retry.
+// Error returned with no items to retry denotes the failure of the whole batch, so all the pending items are retried.
+type BatchFunc[T any] func(pending []T) ([]T, error)
+
+// ErrBatchIncomplete is returned when some of the batch items have never succeeded
+// and the operation has not returned any error.
+var ErrBatchIncomplete = errors.New("batch incomplete")
+
+// ItemError is the error of the item processed by SupplyEach.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("Item %d: %v", e.Index, e.Err)
+}
+
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+// SupplyEach runs the operation for each item, retrying only the items which failed, according to the policy.
+// Each retry round runs the failed items again, using at most concurrency goroutines
+// (non-positive concurrency runs all the pending items concurrently).
+//
+// Returns per-item results and errors (nil for the succeeded items).
+// Returned error joins ItemError of each item which has never succeeded. In case context is canceled,
+// the joined error is wrapped in DeadlineExceededError.
+func SupplyEach[In, Out any](
+	ctx context.Context, slp Sleeper, items []In, supply EachFunc[In, Out], p policy, concurrency int, opts ...Option,
+) ([]Out, []error, error) {
+	results := make([]Out, len(items))
+	errs := make([]error, len(items))
+	pending := make([]int, len(items))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	_, err := Supply(ctx, slp, func() ([]Out, error) {
+		supplyEachPending(items, pending, supply, concurrency, results, errs)
+		failed := make([]error, 0, len(pending))
+		stillPending := pending[:0]
+		for _, i := range pending {
+			if errs[i] != nil {
+				stillPending = append(stillPending, i)
+				failed = append(failed, ItemError{Index: i, Err: errs[i]})
+			}
+		}
+		pending = stillPending
+		return results, errors.Join(failed...)
+	}, p, opts...)
+
+	return results, errs, err
+}
+
+func supplyEachPending[In, Out any](
+	items []In, pending []int, supply EachFunc[In, Out], concurrency int, results []Out, errs []error,
+) {
+	if concurrency <= 0 {
+		concurrency = len(pending)
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, i := range pending {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i], errs[i] = supply(items[i])
+		}()
+	}
+	wg.Wait()
+}
+
+// SupplyBatch submits the items to the batch operation and resubmits only the items reported as failed,
+// according to the policy, until there are no items left or the attempts run out.
+//
+// Returns the items which have never succeeded together with the last error
+// (ErrBatchIncomplete if the operation has not returned any). In case context is canceled,
+// the error is wrapped in DeadlineExceededError.
+func SupplyBatch[T any](
+	ctx context.Context, slp Sleeper, items []T, supply BatchFunc[T], p policy, opts ...Option,
+) ([]T, error) {
+	pending := items
+	_, err := Supply(ctx, slp, func() ([]T, error) {
+		failed, err := supply(pending)
+		switch {
+		case len(failed) > 0:
+			pending = failed
+		case err != nil:
+			// whole batch failed, retry all the pending items
+		default:
+			pending = nil
+			return nil, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: %d of %d items failed", ErrBatchIncomplete, len(failed), len(items))
+		}
+		return pending, err
+	}, p, opts...)
+	return pending, err
+}
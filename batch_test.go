@@ -0,0 +1,184 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+)
+
+func Test_SupplyEach_ShouldRetryOnlyFailedItems(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	var mu sync.Mutex
+	calls := map[int]int{}
+	supplier := func(item int) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[item]++
+		if item%2 == 1 && calls[item] < 2 {
+			return "", assert.AnError
+		}
+		return strconv.Itoa(item), nil
+	}
+
+	res, errs, err := retry.SupplyEach(context.Background(), noSleep(), []int{0, 1, 2, 3}, supplier, p, 2)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "1", "2", "3"}, res)
+	assert.Equal(t, []error{nil, nil, nil, nil}, errs)
+	assert.Equal(t, map[int]int{0: 1, 1: 2, 2: 1, 3: 2}, calls)
+}
+
+func Test_SupplyEach_ShouldJoinErrorsOfItemsWhichNeverSucceeded(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()
+	errPermanent := errors.New("permanent")
+	supplier := func(item string) (int, error) {
+		if item == "bad" {
+			return 0, errPermanent
+		}
+		return len(item), nil
+	}
+
+	res, errs, err := retry.SupplyEach(context.Background(), noSleep(), []string{"ok", "bad", "fine"}, supplier, p, 0)
+
+	require.ErrorIs(t, err, errPermanent)
+	var itemErr retry.ItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, 1, itemErr.Index)
+	assert.Equal(t, []int{2, 0, 4}, res)
+	assert.Equal(t, []error{nil, errPermanent, nil}, errs)
+}
+
+func Test_SupplyEach_ShouldLimitConcurrency(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().Build()
+	var inFlight, maxInFlight atomic.Int64
+	supplier := func(item int) (int, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			current := maxInFlight.Load()
+			if n <= current || maxInFlight.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return item, nil
+	}
+
+	_, _, err := retry.SupplyEach(context.Background(), noSleep(), make([]int, 12), supplier, p, 3)
+
+	require.NoError(t, err)
+	assert.LessOrEqual(t, maxInFlight.Load(), int64(3))
+}
+
+func Test_SupplyEach_ShouldSucceedForNoItems(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().Build()
+	supplier := func(item int) (int, error) { return item, nil }
+
+	res, errs, err := retry.SupplyEach(context.Background(), noSleep(), nil, supplier, p, 0)
+
+	require.NoError(t, err)
+	assert.Empty(t, res)
+	assert.Empty(t, errs)
+}
+
+func Test_SupplyBatch_ShouldResubmitOnlyRemainingItems(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	var submitted [][]int
+	supplier := func(pending []int) ([]int, error) {
+		submitted = append(submitted, pending)
+		if len(pending) > 1 {
+			return pending[1:], nil
+		}
+		return nil, nil
+	}
+
+	remaining, err := retry.SupplyBatch(context.Background(), noSleep(), []int{1, 2, 3}, supplier, p)
+
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Equal(t, [][]int{{1, 2, 3}, {2, 3}, {3}}, submitted)
+}
+
+func Test_SupplyBatch_ShouldReportItemsWhichNeverSucceeded(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()
+	supplier := func(pending []string) ([]string, error) {
+		return pending[len(pending)-1:], nil
+	}
+
+	remaining, err := retry.SupplyBatch(context.Background(), noSleep(), []string{"a", "b", "c"}, supplier, p)
+
+	require.ErrorIs(t, err, retry.ErrBatchIncomplete)
+	assert.Equal(t, []string{"c"}, remaining)
+}
+
+func Test_SupplyBatch_ShouldRetryWholeBatchOnError(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	var submitted [][]int
+	supplier := func(pending []int) ([]int, error) {
+		submitted = append(submitted, pending)
+		switch len(submitted) {
+		case 1:
+			return nil, assert.AnError
+		case 2:
+			return pending[:1], assert.AnError
+		default:
+			return nil, nil
+		}
+	}
+
+	remaining, err := retry.SupplyBatch(context.Background(), noSleep(), []int{1, 2}, supplier, p)
+
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Equal(t, [][]int{{1, 2}, {1, 2}, {1}}, submitted)
+}
+
+func Test_SupplyBatch_ShouldReturnLastError(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()
+	supplier := func([]int) ([]int, error) {
+		return nil, assert.AnError
+	}
+
+	remaining, err := retry.SupplyBatch(context.Background(), noSleep(), []int{1, 2}, supplier, p)
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, []int{1, 2}, remaining)
+}
@@ -0,0 +1,120 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	budgetWindow  = 10 * time.Second
+	budgetBuckets = 10
+)
+
+// ErrBudgetExhausted is returned when the retry budget does not allow another retry.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// Budget limits the number of retries shared across calls, so retries do not multiply the load during outages.
+//
+// Within a sliding 10 seconds window, budget allows retries at a ratio of successful calls
+// (e.g. ratio 0.1 allows 1 retry per 10 successful calls) plus a minimum rate of retries per second.
+// Budget is safe for concurrent use.
+type Budget struct {
+	mu      sync.Mutex
+	clk     Clock
+	ratio   float64
+	reserve float64
+	buckets [budgetBuckets]budgetBucket
+	current int
+	start   time.Time
+}
+
+type budgetBucket struct {
+	deposits    int64
+	withdrawals int64
+}
+
+// NewBudget creates retry budget allowing retries at ratio of successful calls plus minRetriesPerSecond retries.
+// Clock measures the budget window, system clock if nil.
+func NewBudget(clk Clock, ratio, minRetriesPerSecond float64) *Budget {
+	if clk == nil {
+		clk = SystemClock()
+	}
+	return &Budget{
+		clk:     clk,
+		ratio:   ratio,
+		reserve: minRetriesPerSecond * budgetWindow.Seconds(),
+		start:   clk.Now(),
+	}
+}
+
+// Balance returns the number of retries currently allowed by the budget.
+func (b *Budget) Balance() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.balance()
+}
+
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	b.buckets[b.current].deposits++
+}
+
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	if b.balance() < 1 {
+		return false
+	}
+	b.buckets[b.current].withdrawals++
+	return true
+}
+
+func (b *Budget) balance() float64 {
+	var deposits, withdrawals int64
+	for _, bucket := range b.buckets {
+		deposits += bucket.deposits
+		withdrawals += bucket.withdrawals
+	}
+	return float64(deposits)*b.ratio + b.reserve - float64(withdrawals)
+}
+
+// advance moves the sliding window to the current time, discarding expired buckets.
+func (b *Budget) advance() {
+	bucketDuration := budgetWindow / budgetBuckets
+	elapsed := int(b.clk.Now().Sub(b.start) / bucketDuration)
+	if elapsed <= 0 {
+		return
+	}
+	for i := range min(elapsed, budgetBuckets) {
+		b.buckets[(b.current+1+i)%budgetBuckets] = budgetBucket{}
+	}
+	b.current = (b.current + elapsed) % budgetBuckets
+	b.start = b.start.Add(time.Duration(elapsed) * bucketDuration)
+}
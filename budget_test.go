@@ -0,0 +1,153 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+func Test_Budget_ShouldFailFastWhenExhausted(t *testing.T) {
+	t.Parallel()
+	budget := retry.NewBudget(clock.NewFakeClock(), 0.1, 0)
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(5)).Build()
+	calls := 0
+	supplier := func() (int, error) {
+		calls++
+		return calls, assert.AnError
+	}
+
+	res, err := retry.Supply(context.Background(), noSleep(), supplier, p, retry.WithBudget(budget))
+
+	require.ErrorIs(t, err, retry.ErrBudgetExhausted)
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 1, res)
+	assert.Equal(t, 1, calls)
+}
+
+func Test_Budget_ShouldAllowRetriesAtRatioOfSuccessfulCalls(t *testing.T) {
+	t.Parallel()
+	budget := retry.NewBudget(clock.NewFakeClock(), 0.5, 0)
+	p := retry.Policy().FixedDelay().WithMaxAttemptsIndefinite().Build()
+	succeed := func() error { return nil }
+	fail := func() error { return assert.AnError }
+
+	for range 4 {
+		require.NoError(t, retry.Run(context.Background(), noSleep(), succeed, p, retry.WithBudget(budget)))
+	}
+	assert.InDelta(t, 2.0, budget.Balance(), 0.001)
+
+	calls := 0
+	err := retry.Run(context.Background(), noSleep(), func() error {
+		calls++
+		return fail()
+	}, p, retry.WithBudget(budget))
+
+	require.ErrorIs(t, err, retry.ErrBudgetExhausted)
+	assert.Equal(t, 3, calls)
+}
+
+func Test_Budget_ShouldAllowMinimumRateOfRetries(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	budget := retry.NewBudget(clk, 0, 0.2)
+	p := retry.Policy().FixedDelay().WithMaxAttemptsIndefinite().Build()
+	calls := 0
+	fail := func() error {
+		calls++
+		return assert.AnError
+	}
+
+	err := retry.Run(context.Background(), noSleep(), fail, p, retry.WithBudget(budget))
+
+	require.ErrorIs(t, err, retry.ErrBudgetExhausted)
+	assert.Equal(t, 3, calls)
+	assert.InDelta(t, 0.0, budget.Balance(), 0.001)
+
+	clk.Advance(5 * time.Second)
+	assert.InDelta(t, 0.0, budget.Balance(), 0.001)
+
+	clk.Advance(5 * time.Second)
+	assert.InDelta(t, 2.0, budget.Balance(), 0.001)
+}
+
+func Test_Budget_ShouldExpireDepositsOutsideWindow(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	budget := retry.NewBudget(clk, 1, 0)
+	p := retry.Policy().FixedDelay().Build()
+	succeed := func() error { return nil }
+
+	require.NoError(t, retry.Run(context.Background(), noSleep(), succeed, p, retry.WithBudget(budget)))
+	assert.InDelta(t, 1.0, budget.Balance(), 0.001)
+
+	clk.Advance(9 * time.Second)
+	assert.InDelta(t, 1.0, budget.Balance(), 0.001)
+
+	clk.Advance(time.Second)
+	assert.InDelta(t, 0.0, budget.Balance(), 0.001)
+}
+
+func Test_Budget_ShouldNotOverdrawUnderConcurrency(t *testing.T) {
+	t.Parallel()
+	budget := retry.NewBudget(clock.NewFakeClock(), 0, 5)
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()
+	var calls atomic.Int64
+	fail := func() error {
+		calls.Add(1)
+		return assert.AnError
+	}
+
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = retry.Run(context.Background(), noSleep(), fail, p, retry.WithBudget(budget))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(250), calls.Load())
+	assert.InDelta(t, 0.0, budget.Balance(), 0.001)
+}
+
+func noSleep() retry.Sleeper {
+	return retry.SleeperF(func(time.Duration) {})
+}
+
+func Test_Budget_ShouldUseSystemClockWhenNil(t *testing.T) {
+	t.Parallel()
+	budget := retry.NewBudget(nil, 0.1, 1)
+
+	assert.InDelta(t, 10.0, budget.Balance(), 0.001)
+}
@@ -38,6 +38,10 @@ func (b Builder) FixedDelay() *FixedDelayPolicyBuilder {
 	return &FixedDelayPolicyBuilder{}
 }
 
+func (b Builder) Adaptive() *AdaptivePolicyBuilder {
+	return &AdaptivePolicyBuilder{}
+}
+
 type BackOffPolicyBuilder struct {
 	initialInterval    time.Duration
 	maxInterval        time.Duration
@@ -191,3 +195,129 @@ func (b FixedDelayPolicyBuilder) resolveMaxAttempts() int64 {
 	}
 	return b.maxAttempts
 }
+
+type AdaptivePolicyBuilder struct {
+	policy         policy
+	clk            Clock
+	initialRate    float64
+	minRate        float64
+	maxRate        float64
+	decreaseFactor float64
+	increaseStep   float64
+	isThrottling   func(error) bool
+}
+
+func (b AdaptivePolicyBuilder) WithPolicy(p policy) AdaptivePolicyBuilder {
+	b.policy = p
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithClock(clk Clock) AdaptivePolicyBuilder {
+	b.clk = clk
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithInitialRate(initialRate float64) AdaptivePolicyBuilder {
+	b.initialRate = initialRate
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithMinRate(minRate float64) AdaptivePolicyBuilder {
+	b.minRate = minRate
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithMaxRate(maxRate float64) AdaptivePolicyBuilder {
+	b.maxRate = maxRate
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithDecreaseFactor(decreaseFactor float64) AdaptivePolicyBuilder {
+	b.decreaseFactor = decreaseFactor
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithIncreaseStep(increaseStep float64) AdaptivePolicyBuilder {
+	b.increaseStep = increaseStep
+	return b
+}
+
+func (b AdaptivePolicyBuilder) WithThrottlingClassifier(isThrottling func(error) bool) AdaptivePolicyBuilder {
+	b.isThrottling = isThrottling
+	return b
+}
+
+func (b AdaptivePolicyBuilder) Build() *AdaptivePolicy {
+	clk := b.resolveClock()
+	maxRate := b.resolveMaxRate()
+	minRate := min(b.resolveMinRate(), maxRate)
+	rate := min(max(b.resolveInitialRate(), minRate), maxRate)
+	return &AdaptivePolicy{
+		policy:         b.resolvePolicy(),
+		clk:            clk,
+		minRate:        minRate,
+		maxRate:        maxRate,
+		decreaseFactor: b.resolveDecreaseFactor(),
+		increaseStep:   b.resolveIncreaseStep(),
+		isThrottling:   b.resolveThrottlingClassifier(),
+		rate:           rate,
+		tokens:         max(rate, 1),
+		lastRefill:     clk.Now(),
+	}
+}
+
+func (b AdaptivePolicyBuilder) resolvePolicy() policy {
+	if b.policy == nil {
+		return Policy().BackOff().Build()
+	}
+	return b.policy
+}
+
+func (b AdaptivePolicyBuilder) resolveClock() Clock {
+	if b.clk == nil {
+		return SystemClock()
+	}
+	return b.clk
+}
+
+func (b AdaptivePolicyBuilder) resolveInitialRate() float64 {
+	if b.initialRate <= 0 {
+		return defaultInitialRate
+	}
+	return b.initialRate
+}
+
+func (b AdaptivePolicyBuilder) resolveMinRate() float64 {
+	if b.minRate <= 0 {
+		return defaultMinRate
+	}
+	return b.minRate
+}
+
+func (b AdaptivePolicyBuilder) resolveMaxRate() float64 {
+	if b.maxRate <= 0 {
+		return defaultMaxRate
+	}
+	return b.maxRate
+}
+
+func (b AdaptivePolicyBuilder) resolveDecreaseFactor() float64 {
+	if b.decreaseFactor <= 0 || b.decreaseFactor >= 1 {
+		return defaultDecreaseFactor
+	}
+	return b.decreaseFactor
+}
+
+func (b AdaptivePolicyBuilder) resolveIncreaseStep() float64 {
+	if b.increaseStep <= 0 {
+		return defaultIncreaseStep
+	}
+	return b.increaseStep
+}
+
+func (b AdaptivePolicyBuilder) resolveThrottlingClassifier() func(error) bool {
+	if b.isThrottling == nil {
+		return func(error) bool { return true }
+	}
+	return b.isThrottling
+}
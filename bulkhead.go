@@ -0,0 +1,124 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBulkheadFull is returned when the bulkhead rejects the call, because all of its slots and queue are taken
+// or the queue timeout elapses.
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// Bulkhead limits the number of concurrent calls per operation name, so the slow dependency cannot consume
+// all the goroutines. Calls exceeding the limit wait in a bounded queue for at most the queue timeout.
+// Bulkhead is safe for concurrent use.
+type Bulkhead struct {
+	clk           Clock
+	maxConcurrent int
+	maxQueue      int
+	queueTimeout  time.Duration
+
+	mu           sync.Mutex
+	compartments map[string]*compartment
+}
+
+type compartment struct {
+	slots  chan struct{}
+	queued int
+}
+
+// NewBulkhead creates bulkhead permitting maxConcurrent calls per operation name, with at most maxQueue calls
+// waiting for at most queueTimeout (non-positive timeout waits until the context is canceled).
+// Zero maxQueue rejects the calls exceeding the limit immediately. Clock measures the queue timeout,
+// system clock if nil.
+func NewBulkhead(clk Clock, maxConcurrent, maxQueue int, queueTimeout time.Duration) *Bulkhead {
+	if clk == nil {
+		clk = SystemClock()
+	}
+	return &Bulkhead{
+		clk:           clk,
+		maxConcurrent: max(maxConcurrent, 1),
+		maxQueue:      max(maxQueue, 0),
+		queueTimeout:  queueTimeout,
+		compartments:  map[string]*compartment{},
+	}
+}
+
+// InFlight returns the number of calls in flight for the operation name.
+func (b *Bulkhead) InFlight(name string) int {
+	return len(b.compartment(name).slots)
+}
+
+// acquire acquires the slot for the operation name, returns function releasing the slot.
+func (b *Bulkhead) acquire(ctx context.Context, name string) (func(), error) {
+	c := b.compartment(name)
+	release := func() {
+		<-c.slots
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	b.mu.Lock()
+	if c.queued >= b.maxQueue {
+		b.mu.Unlock()
+		return nil, ErrBulkheadFull
+	}
+	c.queued++
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		c.queued--
+		b.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if b.queueTimeout > 0 {
+		timeout = b.clk.After(b.queueTimeout)
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ErrBulkheadFull
+	}
+}
+
+func (b *Bulkhead) compartment(name string) *compartment {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.compartments[name]
+	if !ok {
+		c = &compartment{slots: make(chan struct{}, b.maxConcurrent)}
+		b.compartments[name] = c
+	}
+	return c
+}
@@ -0,0 +1,162 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+// occupy runs operation in the bulkhead until the returned function is called.
+func occupy(t *testing.T, b *retry.Bulkhead, name string) func() {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	go func() {
+		done <- retry.Run(context.Background(), noSleep(), func() error {
+			close(started)
+			<-release
+			return nil
+		}, once, retry.WithBulkhead(b, name))
+	}()
+	<-started
+	return func() {
+		close(release)
+		require.NoError(t, <-done)
+	}
+}
+
+func Test_Bulkhead_ShouldRejectCallsExceedingLimit(t *testing.T) {
+	t.Parallel()
+	b := retry.NewBulkhead(clock.NewFakeClock(), 2, 0, 0)
+	defer occupy(t, b, "s3-upload")()
+	defer occupy(t, b, "s3-upload")()
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	called := false
+
+	err := retry.Run(context.Background(), noSleep(), func() error {
+		called = true
+		return nil
+	}, once, retry.WithBulkhead(b, "s3-upload"))
+
+	require.ErrorIs(t, err, retry.ErrBulkheadFull)
+	assert.False(t, called)
+	assert.Equal(t, 2, b.InFlight("s3-upload"))
+}
+
+func Test_Bulkhead_ShouldLimitCallsPerOperationName(t *testing.T) {
+	t.Parallel()
+	b := retry.NewBulkhead(clock.NewFakeClock(), 1, 0, 0)
+	defer occupy(t, b, "s3-upload")()
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+
+	err := retry.Run(context.Background(), noSleep(), func() error { return nil }, once,
+		retry.WithBulkhead(b, "payments-api"))
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, b.InFlight("payments-api"))
+}
+
+func Test_Bulkhead_ShouldRetryRejectedCallsPerPolicy(t *testing.T) {
+	t.Parallel()
+	b := retry.NewBulkhead(clock.NewFakeClock(), 1, 0, 0)
+	release := occupy(t, b, "s3-upload")
+	p := retry.Policy().FixedDelay().WithInterval(time.Second).WithMaxAttempts(int64(3)).Build()
+	var delays []time.Duration
+	slp := retry.SleeperF(func(d time.Duration) {
+		delays = append(delays, d)
+		if len(delays) == 2 {
+			release()
+		}
+	})
+	calls := 0
+
+	err := retry.Run(context.Background(), slp, func() error {
+		calls++
+		return nil
+	}, p, retry.WithBulkhead(b, "s3-upload"))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, delays)
+}
+
+func Test_Bulkhead_ShouldQueueCallsUntilSlotIsReleased(t *testing.T) {
+	t.Parallel()
+	b := retry.NewBulkhead(clock.NewFakeClock(), 1, 1, 0)
+	release := occupy(t, b, "s3-upload")
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	done := make(chan error)
+
+	go func() {
+		done <- retry.Run(context.Background(), noSleep(), func() error { return nil }, once,
+			retry.WithBulkhead(b, "s3-upload"))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release()
+
+	require.NoError(t, <-done)
+}
+
+func Test_Bulkhead_ShouldRejectQueuedCallsAfterQueueTimeout(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	b := retry.NewBulkhead(clk, 1, 1, time.Second)
+	defer occupy(t, b, "s3-upload")()
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	done := make(chan error)
+
+	go func() {
+		done <- retry.Run(context.Background(), noSleep(), func() error { return nil }, once,
+			retry.WithBulkhead(b, "s3-upload"))
+	}()
+	clk.BlockUntil(1)
+
+	err := retry.Run(context.Background(), noSleep(), func() error { return nil }, once,
+		retry.WithBulkhead(b, "s3-upload"))
+	require.ErrorIs(t, err, retry.ErrBulkheadFull)
+
+	clk.Advance(time.Second)
+	require.ErrorIs(t, <-done, retry.ErrBulkheadFull)
+}
+
+func Test_Bulkhead_ShouldUseSystemClockWhenNil(t *testing.T) {
+	t.Parallel()
+	b := retry.NewBulkhead(nil, 1, 1, time.Millisecond)
+	defer occupy(t, b, "s3-upload")()
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+
+	err := retry.Run(context.Background(), noSleep(), func() error { return nil }, once,
+		retry.WithBulkhead(b, "s3-upload"))
+
+	require.ErrorIs(t, err, retry.ErrBulkheadFull)
+}
@@ -0,0 +1,258 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureRateThreshold = 0.5
+	defaultWindowSize           = 20
+	defaultMinimumCalls         = 10
+	defaultCoolDown             = 30 * time.Second
+	defaultHalfOpenProbes       = 1
+)
+
+// ErrCircuitOpen is returned instead of calling the operation while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState represents the circuit breaker state.
+type CircuitState int
+
+const (
+	// CircuitClosed - calls are permitted, outcomes are recorded in the sliding window.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen - calls are rejected until the cool-down elapses.
+	CircuitOpen
+	// CircuitHalfOpen - limited number of probe calls is permitted to decide whether to close the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig configures the circuit breaker. Zero values resolve to the defaults.
+type CircuitBreakerConfig struct {
+	// FailureRateThreshold - failure rate (0, 1] of the calls in the sliding window which opens the circuit,
+	// defaults to 0.5.
+	FailureRateThreshold float64
+	// WindowSize - number of the most recent calls in the sliding window, defaults to 20.
+	WindowSize int
+	// MinimumCalls - number of calls recorded in the sliding window before the failure rate is evaluated,
+	// defaults to 10 (or WindowSize, if lower).
+	MinimumCalls int
+	// CoolDown - time the circuit stays open before switching to half-open, defaults to 30 seconds.
+	CoolDown time.Duration
+	// HalfOpenProbes - number of probe calls permitted in half-open state, all of which must succeed
+	// to close the circuit, defaults to 1.
+	HalfOpenProbes int
+	// OnStateChange - optional callback invoked on each state change.
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker stops calling the failing operation for a cool-down period once the failure rate
+// over a sliding window of recent calls exceeds the threshold. CircuitBreaker is safe for concurrent use.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	clk              Clock
+	cfg              CircuitBreakerConfig
+	state            CircuitState
+	outcomes         []bool
+	next             int
+	recorded         int
+	failures         int
+	openedAt         time.Time
+	halfOpenInFlight int
+	halfOpenPassed   int
+	// generation is incremented on each state change, outcomes of the calls permitted
+	// in the previous generations are ignored.
+	generation uint64
+}
+
+// NewCircuitBreaker creates closed circuit breaker. Clock measures the cool-down, system clock if nil.
+func NewCircuitBreaker(clk Clock, cfg CircuitBreakerConfig) *CircuitBreaker {
+	if clk == nil {
+		clk = SystemClock()
+	}
+	cfg = cfg.resolve()
+	return &CircuitBreaker{
+		clk:      clk,
+		cfg:      cfg,
+		outcomes: make([]bool, cfg.WindowSize),
+	}
+}
+
+// State returns the current circuit breaker state.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && cb.coolDownElapsed() {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow reports whether the call is permitted, reserving a probe call in half-open state.
+// Returns the generation the call was permitted in, to be passed to record.
+func (cb *CircuitBreaker) allow() (uint64, bool) {
+	cb.mu.Lock()
+	var transition func()
+	defer func() {
+		cb.mu.Unlock()
+		if transition != nil {
+			transition()
+		}
+	}()
+
+	if cb.state == CircuitOpen && cb.coolDownElapsed() {
+		transition = cb.transitionTo(CircuitHalfOpen)
+	}
+	switch cb.state {
+	case CircuitClosed:
+		return cb.generation, true
+	case CircuitHalfOpen:
+		if cb.halfOpenInFlight+cb.halfOpenPassed >= cb.cfg.HalfOpenProbes {
+			return cb.generation, false
+		}
+		cb.halfOpenInFlight++
+		return cb.generation, true
+	case CircuitOpen:
+		return cb.generation, false
+	default:
+		return cb.generation, false
+	}
+}
+
+// record records the outcome of the call permitted in the generation.
+// Outcomes of the calls permitted before the last state change are ignored.
+func (cb *CircuitBreaker) record(generation uint64, success bool) {
+	cb.mu.Lock()
+	var transition func()
+	defer func() {
+		cb.mu.Unlock()
+		if transition != nil {
+			transition()
+		}
+	}()
+
+	if generation != cb.generation {
+		return
+	}
+	switch cb.state {
+	case CircuitClosed:
+		cb.recordOutcome(success)
+		if cb.recorded >= cb.cfg.MinimumCalls &&
+			float64(cb.failures)/float64(cb.recorded) >= cb.cfg.FailureRateThreshold {
+			transition = cb.transitionTo(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		cb.halfOpenInFlight--
+		if !success {
+			transition = cb.transitionTo(CircuitOpen)
+			return
+		}
+		cb.halfOpenPassed++
+		if cb.halfOpenPassed >= cb.cfg.HalfOpenProbes {
+			transition = cb.transitionTo(CircuitClosed)
+		}
+	case CircuitOpen:
+		// calls are not permitted in open state
+	}
+}
+
+func (cb *CircuitBreaker) recordOutcome(success bool) {
+	failed := !success
+	if cb.recorded == len(cb.outcomes) {
+		if cb.outcomes[cb.next] {
+			cb.failures--
+		}
+	} else {
+		cb.recorded++
+	}
+	cb.outcomes[cb.next] = failed
+	if failed {
+		cb.failures++
+	}
+	cb.next = (cb.next + 1) % len(cb.outcomes)
+}
+
+func (cb *CircuitBreaker) coolDownElapsed() bool {
+	return !cb.clk.Now().Before(cb.openedAt.Add(cb.cfg.CoolDown))
+}
+
+// transitionTo switches the state and resets the state counters,
+// returns the state change notification to be invoked once the lock is released.
+func (cb *CircuitBreaker) transitionTo(state CircuitState) func() {
+	from := cb.state
+	cb.state = state
+	cb.generation++
+	cb.halfOpenInFlight = 0
+	cb.halfOpenPassed = 0
+	switch state {
+	case CircuitOpen:
+		cb.openedAt = cb.clk.Now()
+	case CircuitClosed:
+		clear(cb.outcomes)
+		cb.next, cb.recorded, cb.failures = 0, 0, 0
+	case CircuitHalfOpen:
+	}
+	if cb.cfg.OnStateChange == nil {
+		return nil
+	}
+	return func() {
+		cb.cfg.OnStateChange(from, state)
+	}
+}
+
+func (c CircuitBreakerConfig) resolve() CircuitBreakerConfig {
+	if c.FailureRateThreshold <= 0 || c.FailureRateThreshold > 1 {
+		c.FailureRateThreshold = defaultFailureRateThreshold
+	}
+	if c.WindowSize <= 0 {
+		c.WindowSize = defaultWindowSize
+	}
+	if c.MinimumCalls <= 0 {
+		c.MinimumCalls = defaultMinimumCalls
+	}
+	c.MinimumCalls = min(c.MinimumCalls, c.WindowSize)
+	if c.CoolDown <= 0 {
+		c.CoolDown = defaultCoolDown
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = defaultHalfOpenProbes
+	}
+	return c
+}
@@ -0,0 +1,265 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+type stateChange struct {
+	from, to retry.CircuitState
+}
+
+type stateChangeRecorder struct {
+	mu      sync.Mutex
+	changes []stateChange
+}
+
+func (r *stateChangeRecorder) record(from, to retry.CircuitState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, stateChange{from: from, to: to})
+}
+
+func (r *stateChangeRecorder) get() []stateChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]stateChange(nil), r.changes...)
+}
+
+func Test_CircuitBreaker_ShouldOpenWhenFailureRateExceeded(t *testing.T) {
+	t.Parallel()
+	var changes stateChangeRecorder
+	cb := retry.NewCircuitBreaker(clock.NewFakeClock(), retry.CircuitBreakerConfig{
+		FailureRateThreshold: 0.5,
+		WindowSize:           4,
+		MinimumCalls:         4,
+		OnStateChange:        changes.record,
+	})
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(6)).Build()
+	calls := 0
+	fail := func() error {
+		calls++
+		return assert.AnError
+	}
+
+	err := retry.Run(context.Background(), noSleep(), fail, p, retry.WithCircuitBreaker(cb))
+
+	require.ErrorIs(t, err, retry.ErrCircuitOpen)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, retry.CircuitOpen, cb.State())
+	assert.Equal(t, []stateChange{{from: retry.CircuitClosed, to: retry.CircuitOpen}}, changes.get())
+}
+
+func Test_CircuitBreaker_ShouldStayClosedBelowFailureRate(t *testing.T) {
+	t.Parallel()
+	cb := retry.NewCircuitBreaker(clock.NewFakeClock(), retry.CircuitBreakerConfig{
+		FailureRateThreshold: 0.6,
+		WindowSize:           4,
+	})
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()
+	calls := 0
+	failEveryOther := func() error {
+		calls++
+		if calls%2 == 1 {
+			return assert.AnError
+		}
+		return nil
+	}
+
+	for range 5 {
+		require.NoError(t, retry.Run(context.Background(), noSleep(), failEveryOther, p, retry.WithCircuitBreaker(cb)))
+	}
+
+	assert.Equal(t, retry.CircuitClosed, cb.State())
+}
+
+func Test_CircuitBreaker_ShouldCloseAfterSuccessfulProbes(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	var changes stateChangeRecorder
+	cb := retry.NewCircuitBreaker(clk, retry.CircuitBreakerConfig{
+		WindowSize:     2,
+		CoolDown:       time.Minute,
+		HalfOpenProbes: 2,
+		OnStateChange:  changes.record,
+	})
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	fail := func() error { return assert.AnError }
+	succeed := func() error { return nil }
+
+	for range 2 {
+		require.ErrorIs(t, retry.Run(context.Background(), noSleep(), fail, once, retry.WithCircuitBreaker(cb)),
+			assert.AnError)
+	}
+	require.ErrorIs(t, retry.Run(context.Background(), noSleep(), succeed, once, retry.WithCircuitBreaker(cb)),
+		retry.ErrCircuitOpen)
+
+	clk.Advance(time.Minute)
+	assert.Equal(t, retry.CircuitHalfOpen, cb.State())
+	require.NoError(t, retry.Run(context.Background(), noSleep(), succeed, once, retry.WithCircuitBreaker(cb)))
+	require.NoError(t, retry.Run(context.Background(), noSleep(), succeed, once, retry.WithCircuitBreaker(cb)))
+
+	assert.Equal(t, retry.CircuitClosed, cb.State())
+	assert.Equal(t, []stateChange{
+		{from: retry.CircuitClosed, to: retry.CircuitOpen},
+		{from: retry.CircuitOpen, to: retry.CircuitHalfOpen},
+		{from: retry.CircuitHalfOpen, to: retry.CircuitClosed},
+	}, changes.get())
+}
+
+func Test_CircuitBreaker_ShouldReopenWhenProbeFails(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	var changes stateChangeRecorder
+	cb := retry.NewCircuitBreaker(clk, retry.CircuitBreakerConfig{
+		WindowSize:    1,
+		CoolDown:      time.Minute,
+		OnStateChange: changes.record,
+	})
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	fail := func() error { return assert.AnError }
+
+	require.ErrorIs(t, retry.Run(context.Background(), noSleep(), fail, once, retry.WithCircuitBreaker(cb)),
+		assert.AnError)
+	clk.Advance(time.Minute)
+	require.ErrorIs(t, retry.Run(context.Background(), noSleep(), fail, once, retry.WithCircuitBreaker(cb)),
+		assert.AnError)
+
+	assert.Equal(t, retry.CircuitOpen, cb.State())
+	assert.Equal(t, []stateChange{
+		{from: retry.CircuitClosed, to: retry.CircuitOpen},
+		{from: retry.CircuitOpen, to: retry.CircuitHalfOpen},
+		{from: retry.CircuitHalfOpen, to: retry.CircuitOpen},
+	}, changes.get())
+}
+
+func Test_CircuitBreaker_ShouldLimitHalfOpenProbes(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	cb := retry.NewCircuitBreaker(clk, retry.CircuitBreakerConfig{
+		WindowSize: 1,
+		CoolDown:   time.Minute,
+	})
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	fail := func() error { return assert.AnError }
+	require.ErrorIs(t, retry.Run(context.Background(), noSleep(), fail, once, retry.WithCircuitBreaker(cb)),
+		assert.AnError)
+	clk.Advance(time.Minute)
+
+	probeStarted := make(chan struct{})
+	releaseProbe := make(chan struct{})
+	probeDone := make(chan error)
+	go func() {
+		probeDone <- retry.Run(context.Background(), noSleep(), func() error {
+			close(probeStarted)
+			<-releaseProbe
+			return nil
+		}, once, retry.WithCircuitBreaker(cb))
+	}()
+	<-probeStarted
+
+	calls := 0
+	err := retry.Run(context.Background(), noSleep(), func() error {
+		calls++
+		return nil
+	}, once, retry.WithCircuitBreaker(cb))
+	close(releaseProbe)
+
+	require.ErrorIs(t, err, retry.ErrCircuitOpen)
+	assert.Equal(t, 0, calls)
+	require.NoError(t, <-probeDone)
+	assert.Equal(t, retry.CircuitClosed, cb.State())
+}
+
+func Test_CircuitBreaker_ShouldIgnoreOutcomeOfCallPermittedBeforeOpening(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	var changes stateChangeRecorder
+	cb := retry.NewCircuitBreaker(clk, retry.CircuitBreakerConfig{
+		WindowSize:    1,
+		CoolDown:      time.Minute,
+		OnStateChange: changes.record,
+	})
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+	runSlow := func(err error) (chan<- struct{}, <-chan error) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- retry.Run(context.Background(), noSleep(), func() error {
+				close(started)
+				<-release
+				return err
+			}, once, retry.WithCircuitBreaker(cb))
+		}()
+		<-started
+		return release, done
+	}
+
+	releaseSlow, slowDone := runSlow(nil)
+	require.ErrorIs(t, retry.Run(context.Background(), noSleep(), func() error { return assert.AnError }, once,
+		retry.WithCircuitBreaker(cb)), assert.AnError)
+	clk.Advance(time.Minute)
+	releaseProbe, probeDone := runSlow(assert.AnError)
+	close(releaseSlow)
+	require.NoError(t, <-slowDone)
+	assert.Equal(t, retry.CircuitHalfOpen, cb.State())
+	close(releaseProbe)
+	require.ErrorIs(t, <-probeDone, assert.AnError)
+
+	assert.Equal(t, retry.CircuitOpen, cb.State())
+	assert.Equal(t, []stateChange{
+		{from: retry.CircuitClosed, to: retry.CircuitOpen},
+		{from: retry.CircuitOpen, to: retry.CircuitHalfOpen},
+		{from: retry.CircuitHalfOpen, to: retry.CircuitOpen},
+	}, changes.get())
+}
+
+func Test_CircuitState_String(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "closed", retry.CircuitClosed.String())
+	assert.Equal(t, "open", retry.CircuitOpen.String())
+	assert.Equal(t, "half-open", retry.CircuitHalfOpen.String())
+}
+
+func Test_CircuitBreaker_ShouldUseSystemClockWhenNil(t *testing.T) {
+	t.Parallel()
+	cb := retry.NewCircuitBreaker(nil, retry.CircuitBreakerConfig{WindowSize: 1})
+	once := retry.Policy().FixedDelay().WithMaxAttempts(int64(1)).Build()
+
+	err := retry.Run(context.Background(), noSleep(), func() error { return assert.AnError }, once,
+		retry.WithCircuitBreaker(cb))
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, retry.CircuitOpen, cb.State())
+}
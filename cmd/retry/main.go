@@ -0,0 +1,312 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Command retry runs the command, retrying it according to the retry policy.
+//
+// Usage:
+//
+//	retry [flags] -- command [args...]
+//
+// Example:
+//
+//	retry --policy 'backoff(initial=1s,max=30s,attempts=5)' --on-exit-codes 7,28 -- curl -fsS https://example.com
+//
+// The command is retried when it exits with a non-zero exit code. Retries may be limited to the chosen exit codes
+// and to the attempts which output matches the stdout or stderr patterns. Signals received by retry
+// are forwarded to the running command and stop the retries. Retry exits with the exit code
+// of the last command attempt.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+const (
+	exitCodeUsage       = 2
+	exitCodeNotExecuted = 127
+	exitCodeSignaled    = 128
+)
+
+var errUsage = errors.New("command is missing")
+
+func main() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	code := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr, signals)
+	signal.Stop(signals)
+	os.Exit(code)
+}
+
+type config struct {
+	policy        retry.PolicyConfig
+	exitCodes     exitCodesValue
+	stdoutPattern regexpValue
+	stderrPattern regexpValue
+	quiet         bool
+}
+
+func parseConfig(args []string, stderr io.Writer) (config, []string, error) {
+	var cfg config
+	fs := flag.NewFlagSet("retry", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: retry [flags] -- command [args...]")
+		fs.PrintDefaults()
+	}
+	fs.TextVar(&cfg.policy, "policy", retry.PolicyConfig{},
+		"retry `policy`, e.g. backoff(initial=1s,max=30s,attempts=5) or fixed(interval=1s,attempts=3)")
+	fs.Var(&cfg.exitCodes, "on-exit-codes", "comma separated exit `codes` retried, all non-zero exit codes if empty")
+	fs.Var(&cfg.stdoutPattern, "stdout-pattern", "retry only if stdout of the failed attempt matches the `regexp`")
+	fs.Var(&cfg.stderrPattern, "stderr-pattern", "retry only if stderr of the failed attempt matches the `regexp`")
+	fs.BoolVar(&cfg.quiet, "quiet", false, "do not print attempt logs")
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return cfg, nil, errUsage
+	}
+	return cfg, fs.Args(), nil
+}
+
+func run(
+	ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, signals <-chan os.Signal,
+) int {
+	cfg, command, err := parseConfig(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return exitCodeUsage
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r := &runner{cfg: cfg, command: command, stdin: stdin, stdout: stdout, stderr: stderr}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				r.signal(sig)
+				cancel()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = retry.Run(ctx, contextSleeper(ctx), r.attempt, cfg.policy, retry.WithRetryListener(func(e retry.RetryEvent) {
+		r.logf("attempt %d failed: %v, retrying in %v", e.Attempt, e.Err, e.Delay)
+	}))
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		r.logf("interrupted after %d attempts", r.attempts)
+	default:
+		r.logf("giving up after %d attempts: %v", r.attempts, err)
+	}
+	return r.exitCode
+}
+
+// contextSleeper sleeps until the duration elapses or the context is done.
+func contextSleeper(ctx context.Context) retry.Sleeper {
+	return retry.SleeperF(func(d time.Duration) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// runner runs the command attempts.
+type runner struct {
+	cfg      config
+	command  []string
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	mu       sync.Mutex
+	process  *os.Process
+	attempts int
+	exitCode int
+}
+
+func (r *runner) attempt() error {
+	r.attempts++
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(r.command[0], r.command[1:]...)
+	cmd.Stdin = r.stdin
+	cmd.Stdout = capture(r.stdout, &stdout, r.cfg.stdoutPattern)
+	cmd.Stderr = capture(r.stderr, &stderr, r.cfg.stderrPattern)
+	if err := r.start(cmd); err != nil {
+		r.exitCode = exitCodeNotExecuted
+		return retry.Permanent(err)
+	}
+	_ = cmd.Wait()
+	r.setProcess(nil)
+	r.exitCode = exitCode(cmd.ProcessState)
+	if r.exitCode == 0 {
+		return nil
+	}
+	err := exitCodeError{Code: r.exitCode}
+	if !r.retryable(stdout.Bytes(), stderr.Bytes()) {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// capture copies the output to the buffer too, if the output is matched with the pattern.
+func capture(w io.Writer, buf *bytes.Buffer, pattern regexpValue) io.Writer {
+	if pattern.re == nil {
+		return w
+	}
+	return io.MultiWriter(w, buf)
+}
+
+func (r *runner) start(cmd *exec.Cmd) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting command: %w", err)
+	}
+	r.process = cmd.Process
+	return nil
+}
+
+func (r *runner) setProcess(process *os.Process) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.process = process
+}
+
+// signal forwards the signal to the running command.
+func (r *runner) signal(sig os.Signal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.process != nil {
+		_ = r.process.Signal(sig)
+	}
+}
+
+func (r *runner) retryable(stdout, stderr []byte) bool {
+	if len(r.cfg.exitCodes) > 0 && !slices.Contains(r.cfg.exitCodes, r.exitCode) {
+		return false
+	}
+	if r.cfg.stdoutPattern.re == nil && r.cfg.stderrPattern.re == nil {
+		return true
+	}
+	return r.cfg.stdoutPattern.matches(stdout) || r.cfg.stderrPattern.matches(stderr)
+}
+
+func (r *runner) logf(format string, args ...any) {
+	if !r.cfg.quiet {
+		_, _ = fmt.Fprintf(r.stderr, "retry: "+format+"\n", args...)
+	}
+}
+
+// exitCode returns the process exit code, 128 + signal number if the process was terminated by a signal.
+func exitCode(state *os.ProcessState) int {
+	if code := state.ExitCode(); code != -1 {
+		return code
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return exitCodeSignaled + int(status.Signal())
+	}
+	return exitCodeSignaled
+}
+
+// exitCodeError is the error of the command attempt which exited with a non-zero exit code.
+type exitCodeError struct {
+	Code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("Exit code %d", e.Code)
+}
+
+// exitCodesValue is flag.Value of comma separated exit codes.
+type exitCodesValue []int
+
+func (v *exitCodesValue) String() string {
+	codes := make([]string, 0, len(*v))
+	for _, code := range *v {
+		codes = append(codes, strconv.Itoa(code))
+	}
+	return strings.Join(codes, ",")
+}
+
+func (v *exitCodesValue) Set(s string) error {
+	for _, field := range strings.Split(s, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return fmt.Errorf("parsing exit code: %w", err)
+		}
+		*v = append(*v, code)
+	}
+	return nil
+}
+
+// regexpValue is flag.Value of regular expression.
+type regexpValue struct {
+	re *regexp.Regexp
+}
+
+func (v *regexpValue) String() string {
+	if v.re == nil {
+		return ""
+	}
+	return v.re.String()
+}
+
+func (v *regexpValue) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return fmt.Errorf("parsing pattern: %w", err)
+	}
+	v.re = re
+	return nil
+}
+
+func (v *regexpValue) matches(output []byte) bool {
+	return v.re != nil && v.re.Match(output)
+}
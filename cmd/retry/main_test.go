@@ -0,0 +1,185 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fastPolicy = "fixed(interval=1ms,attempts=3)"
+
+// script writes the shell script failing with the exit code until it is run for the given number of times.
+func script(t *testing.T, failures int, exitCode int, stderr string) string {
+	t.Helper()
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "counter")
+	path := filepath.Join(dir, "script.sh")
+	content := strings.Join([]string{
+		"#!/bin/sh",
+		"echo x >> " + counter,
+		"echo attempt $(wc -l < " + counter + ")",
+		"if [ $(wc -l < " + counter + ") -le " + strconv.Itoa(failures) + " ]; then",
+		"  echo '" + stderr + "' >&2",
+		"  exit " + strconv.Itoa(exitCode),
+		"fi",
+	}, "\n")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o700))
+	return path
+}
+
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr, make(chan os.Signal))
+	return code, stdout.String(), stderr.String()
+}
+
+func Test_Run_ShouldRetryUntilCommandSucceeds(t *testing.T) {
+	t.Parallel()
+	path := script(t, 2, 1, "failed")
+
+	code, stdout, stderr := runCommand("--policy", fastPolicy, "--", path)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "attempt 1\nattempt 2\nattempt 3\n", stdout)
+	assert.Contains(t, stderr, "retry: attempt 1 failed: Exit code 1, retrying in 1ms")
+	assert.Contains(t, stderr, "retry: attempt 2 failed: Exit code 1, retrying in 1ms")
+}
+
+func Test_Run_ShouldExitWithLastExitCodeWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	path := script(t, 9, 3, "failed")
+
+	code, stdout, stderr := runCommand("--policy", fastPolicy, path)
+
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "attempt 1\nattempt 2\nattempt 3\n", stdout)
+	assert.Contains(t, stderr, "retry: giving up after 3 attempts: Exit code 3")
+}
+
+func Test_Run_ShouldRetryOnlyChosenExitCodes(t *testing.T) {
+	t.Parallel()
+	path := script(t, 9, 4, "failed")
+
+	code, stdout, _ := runCommand("--policy", fastPolicy, "--on-exit-codes", "3, 5", path)
+
+	assert.Equal(t, 4, code)
+	assert.Equal(t, "attempt 1\n", stdout)
+}
+
+func Test_Run_ShouldRetryOnlyWhenOutputMatchesPattern(t *testing.T) {
+	t.Parallel()
+	matching := script(t, 1, 1, "connection refused")
+	other := script(t, 1, 1, "permission denied")
+
+	matchingCode, matchingStdout, _ := runCommand("--policy", fastPolicy, "--stderr-pattern", "refused", matching)
+	otherCode, otherStdout, _ := runCommand("--policy", fastPolicy, "--stderr-pattern", "refused", other)
+
+	assert.Equal(t, 0, matchingCode)
+	assert.Equal(t, "attempt 1\nattempt 2\n", matchingStdout)
+	assert.Equal(t, 1, otherCode)
+	assert.Equal(t, "attempt 1\n", otherStdout)
+}
+
+func Test_Run_ShouldMatchStdoutPattern(t *testing.T) {
+	t.Parallel()
+	path := script(t, 1, 1, "failed")
+
+	code, stdout, _ := runCommand("--policy", fastPolicy, "--stdout-pattern", "attempt 1", path)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "attempt 1\nattempt 2\n", stdout)
+}
+
+func Test_Run_ShouldNotPrintAttemptLogsWhenQuiet(t *testing.T) {
+	t.Parallel()
+	path := script(t, 1, 1, "failed")
+
+	code, _, stderr := runCommand("--policy", fastPolicy, "--quiet", path)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "failed\n", stderr)
+}
+
+func Test_Run_ShouldExitWithNotExecutedCodeWhenCommandIsMissing(t *testing.T) {
+	t.Parallel()
+
+	code, _, stderr := runCommand("--policy", fastPolicy, filepath.Join(t.TempDir(), "missing"))
+
+	assert.Equal(t, exitCodeNotExecuted, code)
+	assert.Contains(t, stderr, "retry: giving up after 1 attempts: starting command")
+}
+
+func Test_Run_ShouldExitWithUsageCodeWhenArgumentsAreInvalid(t *testing.T) {
+	t.Parallel()
+	tests := map[string][]string{
+		"missing command":  {"--policy", fastPolicy},
+		"invalid policy":   {"--policy", "linear()", "true"},
+		"invalid codes":    {"--on-exit-codes", "one", "true"},
+		"invalid pattern":  {"--stderr-pattern", "(", "true"},
+		"undefined option": {"--undefined", "true"},
+	}
+	for name, args := range tests {
+		code, _, stderr := runCommand(args...)
+
+		assert.Equal(t, exitCodeUsage, code, name)
+		assert.Contains(t, stderr, "Usage: retry", name)
+	}
+}
+
+func Test_Run_ShouldForwardSignalsAndStopRetrying(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	signals := make(chan os.Signal, 1)
+	var stdout, stderr bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- run(context.Background(), []string{"--policy", fastPolicy, "sh", "-c", "touch " + marker + "; exec sleep 10"},
+			strings.NewReader(""), &stdout, &stderr, signals)
+	}()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(marker)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	signals <- syscall.SIGTERM
+
+	select {
+	case code := <-done:
+		assert.Equal(t, exitCodeSignaled+int(syscall.SIGTERM), code)
+		assert.Contains(t, stderr.String(), "retry: interrupted after 1 attempts")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "command was not interrupted")
+	}
+}
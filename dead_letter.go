@@ -0,0 +1,256 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const deadLetterFileMode = 0o600
+
+// DeadLetter is the operation the retry functions gave up on.
+type DeadLetter struct {
+	// OperationID identifies the operation.
+	OperationID string `json:"operationId"`
+	// Payload is the operation data, needed to replay the operation.
+	Payload []byte `json:"payload,omitempty"`
+	// Attempts is the history of the failed attempts.
+	Attempts []DeadLetterAttempt `json:"attempts"`
+	// Err is the final error message.
+	Err string `json:"error"`
+	// FailedAt is the time the retry functions gave up.
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// DeadLetterAttempt is the failed attempt of the dead-lettered operation.
+type DeadLetterAttempt struct {
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int64 `json:"attempt"`
+	// Err is the error message of the attempt.
+	Err string `json:"error"`
+	// At is the time the attempt failed.
+	At time.Time `json:"at"`
+}
+
+// DeadLetterSink receives the operations the retry functions gave up on, see WithDeadLetter.
+type DeadLetterSink interface {
+	Put(ctx context.Context, letter DeadLetter) error
+}
+
+type deadLetterConfig struct {
+	sink        DeadLetterSink
+	clk         Clock
+	operationID string
+	payload     []byte
+}
+
+func (c *deadLetterConfig) clock() Clock {
+	if c.clk == nil {
+		return SystemClock()
+	}
+	return c.clk
+}
+
+// newDeadLetter returns the dead letter recording the failed attempts, nil if there is no dead-letter sink.
+func (o *options) newDeadLetter() *DeadLetter {
+	if o.deadLetter == nil {
+		return nil
+	}
+	letter := &DeadLetter{
+		OperationID: o.deadLetter.operationID,
+		Payload:     o.deadLetter.payload,
+	}
+	clk := o.deadLetter.clock()
+	o.failureListeners = append(o.failureListeners, func(attempt int64, err error) {
+		letter.Attempts = append(letter.Attempts, DeadLetterAttempt{Attempt: attempt, Err: err.Error(), At: clk.Now()})
+	})
+	return letter
+}
+
+// deliverDeadLetter puts the dead letter into the sink, joining the sink error with the final error.
+// The letter is delivered even if the context is canceled.
+func (o options) deliverDeadLetter(ctx context.Context, letter *DeadLetter, err error) error {
+	letter.Err = err.Error()
+	letter.FailedAt = o.deadLetter.clock().Now()
+	if putErr := o.deadLetter.sink.Put(context.WithoutCancel(ctx), *letter); putErr != nil {
+		return errors.Join(err, fmt.Errorf("delivering dead letter: %w", putErr))
+	}
+	return err
+}
+
+// ReplayFunc re-drives the dead-lettered operation.
+type ReplayFunc func(ctx context.Context, letter DeadLetter) error
+
+// Replay re-drives the dead-lettered operations through the fresh policy, one by one.
+// Returns the letters which failed again, with the replay attempts (timestamped with the clock, system clock if nil)
+// appended to their history.
+func Replay(
+	ctx context.Context, clk Clock, slp Sleeper, letters []DeadLetter, replay ReplayFunc, p policy, opts ...Option,
+) []DeadLetter {
+	var failed []DeadLetter
+	for _, letter := range letters {
+		sink := NewMemoryDeadLetterSink()
+		err := Run(ctx, slp, func() error {
+			return replay(ctx, letter)
+		}, p, append(opts[:len(opts):len(opts)], WithDeadLetter(sink, clk, letter.OperationID, letter.Payload))...)
+		if err == nil {
+			continue
+		}
+		for _, replayed := range sink.Letters() {
+			replayed.Attempts = append(append([]DeadLetterAttempt(nil), letter.Attempts...), replayed.Attempts...)
+			failed = append(failed, replayed)
+		}
+	}
+	return failed
+}
+
+// MemoryDeadLetterSink keeps the dead letters in memory.
+type MemoryDeadLetterSink struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// NewMemoryDeadLetterSink creates empty MemoryDeadLetterSink.
+func NewMemoryDeadLetterSink() *MemoryDeadLetterSink {
+	return &MemoryDeadLetterSink{}
+}
+
+// Put implements DeadLetterSink.
+func (s *MemoryDeadLetterSink) Put(_ context.Context, letter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+// Letters returns the dead letters.
+func (s *MemoryDeadLetterSink) Letters() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadLetter(nil), s.letters...)
+}
+
+// Drain returns and removes the dead letters, e.g. to replay them.
+func (s *MemoryDeadLetterSink) Drain() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	letters := s.letters
+	s.letters = nil
+	return letters
+}
+
+// FileDeadLetterSink appends the dead letters to the file as JSON lines.
+type FileDeadLetterSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenFileDeadLetterSink opens FileDeadLetterSink appending to the file, creating the file if it does not exist.
+func OpenFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, deadLetterFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("opening dead letter file: %w", err)
+	}
+	return &FileDeadLetterSink{path: path, file: file}, nil
+}
+
+// Put implements DeadLetterSink. The file is synced after each letter.
+func (s *FileDeadLetterSink) Put(_ context.Context, letter DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("encoding dead letter: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing dead letter file: %w", err)
+	}
+	if err = s.file.Sync(); err != nil {
+		return fmt.Errorf("syncing dead letter file: %w", err)
+	}
+	return nil
+}
+
+// Letters reads the dead letters from the file.
+func (s *FileDeadLetterSink) Letters() ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Drain reads and removes the dead letters from the file, e.g. to replay them.
+func (s *FileDeadLetterSink) Drain() ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	letters, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.file.Truncate(0); err != nil {
+		return nil, fmt.Errorf("truncating dead letter file: %w", err)
+	}
+	return letters, nil
+}
+
+func (s *FileDeadLetterSink) read() ([]DeadLetter, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading dead letter file: %w", err)
+	}
+	defer file.Close()
+	var letters []DeadLetter
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return letters, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading dead letter file: %w", err)
+		}
+		var letter DeadLetter
+		if err = json.Unmarshal(line, &letter); err != nil {
+			return nil, fmt.Errorf("decoding dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+}
+
+// Close closes the file.
+func (s *FileDeadLetterSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("closing dead letter file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+var errSinkUnavailable = errors.New("sink unavailable")
+
+func threeAttempts() retry.FixedDelayPolicy {
+	return retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+}
+
+type contextCheckingSink struct {
+	retry.MemoryDeadLetterSink
+	ctxErrs []error
+}
+
+func (s *contextCheckingSink) Put(ctx context.Context, letter retry.DeadLetter) error {
+	s.ctxErrs = append(s.ctxErrs, ctx.Err())
+	return s.MemoryDeadLetterSink.Put(ctx, letter)
+}
+
+type failingSink struct{}
+
+func (failingSink) Put(context.Context, retry.DeadLetter) error {
+	return errSinkUnavailable
+}
+
+func Test_Supply_ShouldDeliverDeadLetterWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	sink := retry.NewMemoryDeadLetterSink()
+	clk := clock.NewFakeClock()
+	start := clk.Now()
+	i := 0
+	supplier := func() (int, error) {
+		i++
+		clk.Advance(time.Second)
+		return i, assert.AnError
+	}
+
+	_, err := retry.Supply(context.Background(), noSleep(), supplier, threeAttempts(),
+		retry.WithDeadLetter(sink, clk, "order-1", []byte(`{"order":1}`)))
+
+	assert.Equal(t, assert.AnError, err)
+	letters := sink.Letters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, "order-1", letters[0].OperationID)
+	assert.JSONEq(t, `{"order":1}`, string(letters[0].Payload))
+	assert.Equal(t, assert.AnError.Error(), letters[0].Err)
+	assert.Equal(t, start.Add(3*time.Second), letters[0].FailedAt)
+	require.Len(t, letters[0].Attempts, 3)
+	for i, attempt := range letters[0].Attempts {
+		assert.Equal(t, int64(i+1), attempt.Attempt)
+		assert.Equal(t, assert.AnError.Error(), attempt.Err)
+		assert.Equal(t, start.Add(time.Duration(i+1)*time.Second), attempt.At)
+	}
+}
+
+func Test_Supply_ShouldNotDeliverDeadLetterOnSuccess(t *testing.T) {
+	t.Parallel()
+	sink := retry.NewMemoryDeadLetterSink()
+	i := 0
+	supplier := func() (int, error) {
+		i++
+		if i < 2 {
+			return i, assert.AnError
+		}
+		return i, nil
+	}
+
+	res, err := retry.Supply(context.Background(), noSleep(), supplier, threeAttempts(),
+		retry.WithDeadLetter(sink, nil, "order-1", nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, res)
+	assert.Empty(t, sink.Letters())
+}
+
+func Test_Supply_ShouldDeliverDeadLetterOfPermanentError(t *testing.T) {
+	t.Parallel()
+	sink := retry.NewMemoryDeadLetterSink()
+
+	err := retry.Run(context.Background(), noSleep(), func() error {
+		return retry.Permanent(assert.AnError)
+	}, threeAttempts(), retry.WithDeadLetter(sink, nil, "order-1", nil))
+
+	require.ErrorIs(t, err, assert.AnError)
+	letters := sink.Letters()
+	require.Len(t, letters, 1)
+	assert.Len(t, letters[0].Attempts, 1)
+}
+
+func Test_Supply_ShouldDeliverDeadLetterWhenContextIsCanceled(t *testing.T) {
+	t.Parallel()
+	sink := &contextCheckingSink{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := retry.Run(ctx, noSleep(), func() error {
+		cancel()
+		return assert.AnError
+	}, threeAttempts(), retry.WithDeadLetter(sink, nil, "order-1", nil))
+
+	require.Error(t, err)
+	require.Len(t, sink.Letters(), 1)
+	assert.Equal(t, []error{nil}, sink.ctxErrs)
+}
+
+func Test_Supply_ShouldJoinDeadLetterSinkError(t *testing.T) {
+	t.Parallel()
+
+	err := retry.Run(context.Background(), noSleep(), func() error {
+		return assert.AnError
+	}, threeAttempts(), retry.WithDeadLetter(failingSink{}, nil, "order-1", nil))
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorIs(t, err, errSinkUnavailable)
+}
+
+func Test_MemoryDeadLetterSink_Drain(t *testing.T) {
+	t.Parallel()
+	sink := retry.NewMemoryDeadLetterSink()
+	require.NoError(t, sink.Put(context.Background(), retry.DeadLetter{OperationID: "a"}))
+
+	drained := sink.Drain()
+
+	assert.Equal(t, []retry.DeadLetter{{OperationID: "a"}}, drained)
+	assert.Empty(t, sink.Letters())
+}
+
+func Test_FileDeadLetterSink_ShouldPersistLetters(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	sink, err := retry.OpenFileDeadLetterSink(path)
+	require.NoError(t, err)
+	for _, id := range []string{"a", "b"} {
+		err = retry.Run(context.Background(), noSleep(), func() error {
+			return assert.AnError
+		}, threeAttempts(), retry.WithDeadLetter(sink, nil, id, []byte(id)))
+		require.ErrorIs(t, err, assert.AnError)
+	}
+	require.NoError(t, sink.Close())
+
+	reopened, err := retry.OpenFileDeadLetterSink(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	letters, err := reopened.Letters()
+
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "a", letters[0].OperationID)
+	assert.Equal(t, []byte("b"), letters[1].Payload)
+	assert.Len(t, letters[1].Attempts, 3)
+}
+
+func Test_FileDeadLetterSink_ShouldDrainLetters(t *testing.T) {
+	t.Parallel()
+	sink, err := retry.OpenFileDeadLetterSink(filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+	require.NoError(t, err)
+	defer sink.Close()
+	require.NoError(t, sink.Put(context.Background(), retry.DeadLetter{OperationID: "a"}))
+
+	drained, err := sink.Drain()
+	require.NoError(t, err)
+	require.NoError(t, sink.Put(context.Background(), retry.DeadLetter{OperationID: "b"}))
+	letters, err := sink.Letters()
+	require.NoError(t, err)
+
+	require.Len(t, drained, 1)
+	assert.Equal(t, "a", drained[0].OperationID)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "b", letters[0].OperationID)
+}
+
+func Test_Replay_ShouldReturnLettersFailingAgain(t *testing.T) {
+	t.Parallel()
+	sink := retry.NewMemoryDeadLetterSink()
+	clk := clock.NewFakeClock()
+	for _, id := range []string{"a", "b"} {
+		_ = retry.Run(context.Background(), noSleep(), func() error {
+			return assert.AnError
+		}, threeAttempts(), retry.WithDeadLetter(sink, clk, id, []byte(id)))
+	}
+	failedAt := clk.Now()
+	clk.Advance(time.Hour)
+	replayed := map[string]int{}
+
+	failed := retry.Replay(context.Background(), clk, noSleep(), sink.Drain(),
+		func(_ context.Context, letter retry.DeadLetter) error {
+			replayed[letter.OperationID]++
+			if letter.OperationID == "b" {
+				return assert.AnError
+			}
+			return nil
+		}, retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build())
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, replayed)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "b", failed[0].OperationID)
+	assert.Equal(t, []byte("b"), failed[0].Payload)
+	require.Len(t, failed[0].Attempts, 5)
+	assert.Equal(t, failedAt, failed[0].Attempts[2].At)
+	assert.Equal(t, failedAt.Add(time.Hour), failed[0].Attempts[4].At)
+	assert.Equal(t, failedAt.Add(time.Hour), failed[0].FailedAt)
+}
@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PrimaryTier is the name of the primary tier of SupplyWithFallback.
+const PrimaryTier = "primary"
+
+// Fallback is the fallback tier of SupplyWithFallback, e.g. secondary region or cached value.
+type Fallback[T any] struct {
+	// Name identifies the tier.
+	Name string
+	// Supply supplies the result.
+	Supply SupplyFunc[T]
+	// Policy is the tier retry policy, nil to call Supply once.
+	Policy policy
+}
+
+// FallbackError is returned by SupplyWithFallback when all the tiers fail.
+type FallbackError struct {
+	// Tier is the name of the last tier, which produced the result.
+	Tier string
+	// Errs are the errors of each tier, in order.
+	Errs []error
+}
+
+func (e FallbackError) Error() string {
+	return fmt.Sprintf("All tiers failed, last tier %q: %v", e.Tier, errors.Join(e.Errs...))
+}
+
+func (e FallbackError) Unwrap() []error {
+	return e.Errs
+}
+
+// SupplyWithFallback supplies the result with the primary operation retried according to the policy and options.
+// Once the policy is exhausted (or the error is permanent), fallbacks are tried in order, each with its own policy.
+//
+// Returns the result together with the name of the tier which produced it (PrimaryTier for the primary operation).
+// If all the tiers fail, FallbackError is returned. In case context is canceled, fallbacks are not tried
+// and DeadlineExceededError is returned.
+func SupplyWithFallback[T any](
+	ctx context.Context, slp Sleeper, supply SupplyFunc[T], p policy, fallbacks []Fallback[T], opts ...Option,
+) (T, string, error) {
+	res, err := Supply(ctx, slp, supply, p, opts...)
+	if err == nil {
+		return res, PrimaryTier, nil
+	}
+	tier := PrimaryTier
+	errs := make([]error, 0, len(fallbacks)+1)
+	errs = append(errs, err)
+	for _, fallback := range fallbacks {
+		if ctx.Err() != nil {
+			return res, tier, DeadlineExceededError[T]{
+				Result: res,
+				Err:    FallbackError{Tier: tier, Errs: errs},
+			}
+		}
+		tier = fallback.Name
+		fallbackPolicy := fallback.Policy
+		if fallbackPolicy == nil {
+			fallbackPolicy = Policy().FixedDelay().WithMaxAttempts(1).Build()
+		}
+		if res, err = Supply(ctx, slp, fallback.Supply, fallbackPolicy); err == nil {
+			return res, tier, nil
+		}
+		errs = append(errs, err)
+	}
+	return res, tier, FallbackError{Tier: tier, Errs: errs}
+}
@@ -0,0 +1,170 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+)
+
+func Test_SupplyWithFallback_ShouldReturnPrimaryResult(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().Build()
+	primary := func() (string, error) { return "primary", nil }
+	fallbackCalled := false
+	fallbacks := []retry.Fallback[string]{{
+		Name: "cache",
+		Supply: func() (string, error) {
+			fallbackCalled = true
+			return "cached", nil
+		},
+	}}
+
+	res, tier, err := retry.SupplyWithFallback(context.Background(), noSleep(), primary, p, fallbacks)
+
+	require.NoError(t, err)
+	assert.Equal(t, "primary", res)
+	assert.Equal(t, retry.PrimaryTier, tier)
+	assert.False(t, fallbackCalled)
+}
+
+func Test_SupplyWithFallback_ShouldTryFallbacksInOrderWithOwnPolicies(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	calls := map[string]int{}
+	supplier := func(tier string, err error) retry.SupplyFunc[string] {
+		return func() (string, error) {
+			calls[tier]++
+			return tier, err
+		}
+	}
+
+	res, tier, err := retry.SupplyWithFallback(context.Background(), noSleep(), supplier("primary", assert.AnError), p,
+		[]retry.Fallback[string]{
+			{
+				Name:   "secondary-region",
+				Supply: supplier("secondary-region", assert.AnError),
+				Policy: retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build(),
+			},
+			{
+				Name:   "cache",
+				Supply: supplier("cache", nil),
+			},
+		})
+
+	require.NoError(t, err)
+	assert.Equal(t, "cache", res)
+	assert.Equal(t, "cache", tier)
+	assert.Equal(t, map[string]int{"primary": 3, "secondary-region": 2, "cache": 1}, calls)
+}
+
+func Test_SupplyWithFallback_ShouldFallBackImmediatelyOnPermanentError(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	primaryCalls := 0
+	primary := func() (string, error) {
+		primaryCalls++
+		return "", retry.Permanent(assert.AnError)
+	}
+	fallbacks := []retry.Fallback[string]{{
+		Name:   "cache",
+		Supply: func() (string, error) { return "cached", nil },
+	}}
+
+	res, tier, err := retry.SupplyWithFallback(context.Background(), noSleep(), primary, p, fallbacks)
+
+	require.NoError(t, err)
+	assert.Equal(t, "cached", res)
+	assert.Equal(t, "cache", tier)
+	assert.Equal(t, 1, primaryCalls)
+}
+
+func Test_SupplyWithFallback_ShouldIdentifyLastTierWhenAllFail(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()
+	errPrimary := errors.New("primary failed")
+	errCache := errors.New("cache miss")
+	primary := func() (int, error) { return 0, errPrimary }
+	fallbacks := []retry.Fallback[int]{{
+		Name:   "cache",
+		Supply: func() (int, error) { return 0, errCache },
+	}}
+
+	_, tier, err := retry.SupplyWithFallback(context.Background(), noSleep(), primary, p, fallbacks)
+
+	assert.Equal(t, "cache", tier)
+	assert.Equal(t, retry.FallbackError{Tier: "cache", Errs: []error{errPrimary, errCache}}, err)
+	assert.ErrorIs(t, err, errPrimary)
+	assert.ErrorIs(t, err, errCache)
+}
+
+func Test_SupplyWithFallback_ShouldNotFallBackWhenContextCanceled(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttemptsIndefinite().Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	primary := func() (int, error) {
+		cancel()
+		return 0, assert.AnError
+	}
+	fallbackCalled := false
+	fallbacks := []retry.Fallback[int]{{
+		Name: "cache",
+		Supply: func() (int, error) {
+			fallbackCalled = true
+			return 1, nil
+		},
+	}}
+
+	_, tier, err := retry.SupplyWithFallback(ctx, noSleep(), primary, p, fallbacks)
+
+	var deadlineErr retry.DeadlineExceededError[int]
+	require.ErrorAs(t, err, &deadlineErr)
+	assert.Equal(t, retry.PrimaryTier, tier)
+	assert.False(t, fallbackCalled)
+}
+
+func Test_SupplyWithFallback_ShouldApplyOptionsToPrimaryTier(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	primary := func() (string, error) { return "", assert.AnError }
+	fallbacks := []retry.Fallback[string]{{
+		Name:   "secondary-region",
+		Supply: func() (string, error) { return "", assert.AnError },
+		Policy: retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build(),
+	}}
+	var retried []int64
+
+	_, tier, err := retry.SupplyWithFallback(context.Background(), noSleep(), primary, p, fallbacks,
+		retry.WithRetryListener(func(e retry.RetryEvent) {
+			retried = append(retried, e.Attempt)
+		}))
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, "secondary-region", tier)
+	assert.Equal(t, []int64{1, 2}, retried)
+}
@@ -0,0 +1,149 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Future is a handle of the retry loop started by SupplyAsync.
+type Future[T any] struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	res      T
+	err      error
+	progress Progress
+}
+
+// Progress describes the progress of the retry loop.
+type Progress struct {
+	// Attempt - number of the current attempt (or the last one, if the retry loop is done), starting at 1.
+	Attempt int64
+	// LastErr - error returned by the last failed attempt, nil if no attempt has failed yet.
+	LastErr error
+	// NextRetryAt - time of the next attempt, zero if the retry loop is not waiting for the next attempt.
+	NextRetryAt time.Time
+}
+
+// SupplyAsync starts the retry loop in a new goroutine and returns its handle.
+// Clock (system clock if nil) is used to report the time of the next attempt.
+// Sleeps between attempts end early once the context is done. See Supply for the retry loop details.
+func SupplyAsync[T any](
+	ctx context.Context, clk Clock, slp Sleeper, supply SupplyFunc[T], p policy, opts ...Option,
+) *Future[T] {
+	if clk == nil {
+		clk = SystemClock()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f := newFuture[T](cancel)
+	go func() {
+		defer cancel()
+		f.complete(Supply(ctx, interruptible(ctx, slp), supply, p, append(opts[:len(opts):len(opts)], f.tracking(clk))...))
+	}()
+	return f
+}
+
+// interruptible returns sleeper returning as soon as the context is done.
+// The underlying sleep is left to finish in the background.
+func interruptible(ctx context.Context, slp Sleeper) Sleeper {
+	return SleeperF(func(d time.Duration) {
+		slept := make(chan struct{})
+		go func() {
+			defer close(slept)
+			slp.Sleep(d)
+		}()
+		select {
+		case <-slept:
+		case <-ctx.Done():
+		}
+	})
+}
+
+func newFuture[T any](cancel context.CancelFunc) *Future[T] {
+	return &Future[T]{
+		done:     make(chan struct{}),
+		cancel:   cancel,
+		progress: Progress{Attempt: 1},
+	}
+}
+
+// tracking returns option updating the progress on each retry.
+func (f *Future[T]) tracking(clk Clock) Option {
+	return WithRetryListener(func(event RetryEvent) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.progress = Progress{
+			Attempt:     event.Attempt + 1,
+			LastErr:     event.Err,
+			NextRetryAt: clk.Now().Add(event.Delay),
+		}
+	})
+}
+
+// complete stores the result of the retry loop and marks the future done.
+func (f *Future[T]) complete(res T, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.res, f.err = res, err
+	if err != nil {
+		f.progress.LastErr = err
+	}
+	f.progress.NextRetryAt = time.Time{}
+	close(f.done)
+}
+
+// Done returns channel closed once the retry loop is done.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits until the retry loop is done and returns its result,
+// or returns context error if the context is canceled first.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.res, f.err
+	case <-ctx.Done():
+		var res T
+		return res, ctx.Err()
+	}
+}
+
+// Cancel cancels the retry loop. The pending sleep is interrupted
+// and the loop stops before the next attempt, returning DeadlineExceededError.
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
+
+// Progress returns the current progress of the retry loop.
+func (f *Future[T]) Progress() Progress {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.progress
+}
@@ -0,0 +1,168 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+// gateSleeper blocks each sleep until released, reporting requested delays.
+type gateSleeper struct {
+	sleeping chan time.Duration
+	release  chan struct{}
+}
+
+func newGateSleeper() gateSleeper {
+	return gateSleeper{
+		sleeping: make(chan time.Duration),
+		release:  make(chan struct{}),
+	}
+}
+
+func (s gateSleeper) Sleep(d time.Duration) {
+	s.sleeping <- d
+	<-s.release
+}
+
+func Test_SupplyAsync_ShouldReturnResult(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().Build()
+	supplier := func() (string, error) { return "done", nil }
+
+	f := retry.SupplyAsync(context.Background(), clock.NewFakeClock(), noSleep(), supplier, p)
+
+	res, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "done", res)
+	assert.Equal(t, retry.Progress{Attempt: 1}, f.Progress())
+	select {
+	case <-f.Done():
+	default:
+		require.Fail(t, "future not done")
+	}
+}
+
+func Test_SupplyAsync_ShouldReportProgress(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	slp := newGateSleeper()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(3)).
+		Build()
+	var calls atomic.Int64
+	supplier := func() (int64, error) {
+		if n := calls.Add(1); n < 3 {
+			return n, assert.AnError
+		}
+		return 3, nil
+	}
+
+	f := retry.SupplyAsync(context.Background(), clk, slp, supplier, p)
+
+	assert.Equal(t, 100*time.Millisecond, <-slp.sleeping)
+	assert.Equal(t, retry.Progress{
+		Attempt:     2,
+		LastErr:     assert.AnError,
+		NextRetryAt: clk.Now().Add(100 * time.Millisecond),
+	}, f.Progress())
+	slp.release <- struct{}{}
+
+	assert.Equal(t, 200*time.Millisecond, <-slp.sleeping)
+	assert.Equal(t, int64(3), f.Progress().Attempt)
+	assert.Equal(t, clk.Now().Add(200*time.Millisecond), f.Progress().NextRetryAt)
+	slp.release <- struct{}{}
+
+	res, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), res)
+	assert.True(t, f.Progress().NextRetryAt.IsZero())
+}
+
+func Test_SupplyAsync_ShouldStopWhenCanceled(t *testing.T) {
+	t.Parallel()
+	slp := newGateSleeper()
+	p := retry.Policy().FixedDelay().WithMaxAttemptsIndefinite().Build()
+	var calls atomic.Int64
+	supplier := func() (int64, error) {
+		return calls.Add(1), assert.AnError
+	}
+
+	f := retry.SupplyAsync(context.Background(), clock.NewFakeClock(), slp, supplier, p)
+	<-slp.sleeping
+	f.Cancel()
+	slp.release <- struct{}{}
+
+	res, err := f.Wait(context.Background())
+	assert.Equal(t, retry.DeadlineExceededError[int64]{Result: 1, Err: assert.AnError}, err)
+	assert.Equal(t, int64(1), res)
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func Test_SupplyAsync_ShouldInterruptSleepWhenCanceled(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttemptsIndefinite().Build()
+	var calls atomic.Int64
+	supplier := func() (int64, error) {
+		return calls.Add(1), assert.AnError
+	}
+
+	f := retry.SupplyAsync(context.Background(), nil, retry.SleeperF(clk.Sleep), supplier, p)
+	clk.BlockUntil(1)
+	f.Cancel()
+
+	res, err := f.Wait(context.Background())
+	assert.Equal(t, retry.DeadlineExceededError[int64]{Result: 1, Err: assert.AnError}, err)
+	assert.Equal(t, int64(1), res)
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func Test_SupplyAsync_WaitShouldReturnWhenContextCanceled(t *testing.T) {
+	t.Parallel()
+	slp := newGateSleeper()
+	p := retry.Policy().FixedDelay().WithMaxAttemptsIndefinite().Build()
+	supplier := func() (int, error) { return 0, assert.AnError }
+
+	f := retry.SupplyAsync(context.Background(), clock.NewFakeClock(), slp, supplier, p)
+	<-slp.sleeping
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := f.Wait(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	f.Cancel()
+	slp.release <- struct{}{}
+	<-f.Done()
+}
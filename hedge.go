@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"fmt"
+)
+
+// HedgeFunc is an operation run by Hedge. Context is canceled once other attempt succeeds.
+type HedgeFunc[T any] func(ctx context.Context) (T, error)
+
+type hedgeResult[T any] struct {
+	res T
+	err error
+}
+
+// Hedge runs speculative parallel attempts of the operation to reduce the tail latency.
+//
+// The first attempt starts immediately. If no attempt has succeeded within the hedge delay,
+// another one is started alongside the pending ones. Hedge delays follow the policy intervals
+// and the policy max attempts bounds the number of concurrent attempts. Failed attempt is replaced
+// by the next one immediately, until max attempts have failed.
+//
+// The first successful result is returned and the remaining attempts are canceled through their context.
+// If all attempts fail, the last error is returned. Policies attempting indefinitely are rejected with
+// ErrInvalidPolicy, as they would not bound the concurrent attempts.
+// In case context is canceled, DeadlineExceededError is returned. Clock schedules the hedge delays,
+// system clock if nil.
+func Hedge[T any](ctx context.Context, clk Clock, supply HedgeFunc[T], p policy) (T, error) {
+	var res T
+	maxAttempts := p.getMaxAttempts()
+	if maxAttempts == undefinedMaxAttempts {
+		return res, fmt.Errorf("%w: hedging requires bounded max attempts", ErrInvalidPolicy)
+	}
+	if clk == nil {
+		clk = SystemClock()
+	}
+	attemptsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult[T])
+	inFlight, failed := int64(0), int64(0)
+	launch := func() {
+		inFlight++
+		go func() {
+			res, err := supply(attemptsCtx)
+			select {
+			case results <- hedgeResult[T]{res: res, err: err}:
+			case <-attemptsCtx.Done():
+			}
+		}()
+	}
+	canLaunch := func() bool {
+		return inFlight < maxAttempts && failed < maxAttempts
+	}
+
+	var err error
+	launch()
+	delay := p.getInitialInterval()
+	hedgeTimer := clk.After(delay)
+	for {
+		select {
+		case <-ctx.Done():
+			return res, DeadlineExceededError[T]{
+				Result: res,
+				Err:    err,
+			}
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				return r.res, nil
+			}
+			failed++
+			res, err = r.res, r.err
+			if canLaunch() {
+				launch()
+			} else if inFlight == 0 {
+				return res, err
+			}
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if canLaunch() {
+				launch()
+				delay = calcNextInterval(delay, p.getMaxInterval(), p.getBackOffCoefficient())
+				hedgeTimer = clk.After(delay)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+func Test_Hedge_ShouldReturnFirstAttemptResultWhenFastEnough(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().FixedDelay().WithInterval(100 * time.Millisecond).WithMaxAttempts(int64(3)).Build()
+	var calls atomic.Int64
+	supplier := func(context.Context) (int64, error) {
+		return calls.Add(1), nil
+	}
+
+	res, err := retry.Hedge(context.Background(), clk, supplier, p)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res)
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func Test_Hedge_ShouldStartHedgedAttemptAfterDelayAndCancelLoser(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().FixedDelay().WithInterval(100 * time.Millisecond).WithMaxAttempts(int64(3)).Build()
+	var calls atomic.Int64
+	loserCanceled := make(chan struct{})
+	supplier := func(ctx context.Context) (string, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(loserCanceled)
+			return "", ctx.Err()
+		}
+		return "hedged", nil
+	}
+
+	go func() {
+		clk.BlockUntil(1)
+		clk.Advance(100 * time.Millisecond)
+	}()
+	res, err := retry.Hedge(context.Background(), clk, supplier, p)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hedged", res)
+	assert.Equal(t, int64(2), calls.Load())
+	select {
+	case <-loserCanceled:
+	case <-time.After(time.Second):
+		require.Fail(t, "losing attempt not canceled")
+	}
+}
+
+func Test_Hedge_ShouldStartNextAttemptImmediatelyAfterFailure(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttempts(int64(3)).Build()
+	var calls atomic.Int64
+	supplier := func(context.Context) (int64, error) {
+		if n := calls.Add(1); n < 3 {
+			return n, assert.AnError
+		}
+		return 3, nil
+	}
+
+	res, err := retry.Hedge(context.Background(), clk, supplier, p)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), res)
+}
+
+func Test_Hedge_ShouldReturnLastErrorWhenAllAttemptsFail(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttempts(int64(2)).Build()
+	var calls atomic.Int64
+	supplier := func(context.Context) (int64, error) {
+		return calls.Add(1), assert.AnError
+	}
+
+	res, err := retry.Hedge(context.Background(), clk, supplier, p)
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, int64(2), res)
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func Test_Hedge_ShouldBoundConcurrentAttemptsByMaxAttempts(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(2)).
+		Build()
+	var calls atomic.Int64
+	supplier := func(ctx context.Context) (int64, error) {
+		calls.Add(1)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		clk.BlockUntil(1)
+		clk.Advance(100 * time.Millisecond)
+		for calls.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		clk.Advance(time.Hour)
+		cancel()
+	}()
+	_, err := retry.Hedge(ctx, clk, supplier, p)
+
+	var deadlineErr retry.DeadlineExceededError[int64]
+	require.ErrorAs(t, err, &deadlineErr)
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func Test_Hedge_ShouldRejectPolicyAttemptingIndefinitely(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().FixedDelay().WithInterval(time.Millisecond).WithMaxAttemptsIndefinite().Build()
+	var calls atomic.Int64
+	supplier := func(context.Context) (int64, error) {
+		return calls.Add(1), assert.AnError
+	}
+
+	_, err := retry.Hedge(context.Background(), clock.NewFakeClock(), supplier, p)
+
+	require.ErrorIs(t, err, retry.ErrInvalidPolicy)
+	assert.Zero(t, calls.Load())
+}
+
+func Test_Hedge_ShouldReplaceFailedAttemptsWithinConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().FixedDelay().WithInterval(100 * time.Millisecond).WithMaxAttempts(int64(2)).Build()
+	var calls, running, maxRunning atomic.Int64
+	supplier := func(ctx context.Context) (int64, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			return n, assert.AnError
+		}
+		maxRunning.Store(max(maxRunning.Load(), running.Add(1)))
+		defer running.Add(-1)
+		<-ctx.Done()
+		return n, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for calls.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		clk.BlockUntil(1)
+		clk.Advance(100 * time.Millisecond)
+		for calls.Load() < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		clk.BlockUntil(1)
+		clk.Advance(time.Hour)
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := retry.Hedge(ctx, clk, supplier, p)
+
+	var deadlineErr retry.DeadlineExceededError[int64]
+	require.ErrorAs(t, err, &deadlineErr)
+	assert.Equal(t, int64(3), calls.Load())
+	assert.Equal(t, int64(2), maxRunning.Load())
+}
@@ -0,0 +1,164 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures optional behaviour of the retry functions.
+type Option func(*options)
+
+type options struct {
+	budget           *Budget
+	breaker          *CircuitBreaker
+	bulkhead         *Bulkhead
+	bulkheadName     string
+	limiter          attemptLimiter
+	retryListeners   []func(RetryEvent)
+	failureListeners []func(attempt int64, err error)
+	deadLetter       *deadLetterConfig
+}
+
+// RetryEvent describes the failed attempt which is going to be retried.
+type RetryEvent struct {
+	// Attempt - number of the failed attempt, starting at 1.
+	Attempt int64
+	// Err - error returned by the failed attempt.
+	Err error
+	// Delay - delay before the next attempt.
+	Delay time.Duration
+}
+
+// WithBudget makes retry functions consult the shared retry budget before each retry (but not the first attempt).
+// Retry functions fail fast with ErrBudgetExhausted once the budget is depleted.
+func WithBudget(b *Budget) Option {
+	return func(o *options) {
+		o.budget = b
+	}
+}
+
+// WithCircuitBreaker guards each attempt with the circuit breaker.
+// While the circuit is open, attempts fail with ErrCircuitOpen without calling the operation,
+// and are retried according to the policy.
+func WithCircuitBreaker(cb *CircuitBreaker) Option {
+	return func(o *options) {
+		o.breaker = cb
+	}
+}
+
+// WithBulkhead limits the number of concurrent attempts of the operation with the given name.
+// Attempts rejected by the full bulkhead fail with ErrBulkheadFull without calling the operation,
+// and are retried according to the policy.
+func WithBulkhead(b *Bulkhead, name string) Option {
+	return func(o *options) {
+		o.bulkhead = b
+		o.bulkheadName = name
+	}
+}
+
+// WithRetryListener registers listener notified about each failed attempt before sleeping until the next attempt.
+func WithRetryListener(listener func(RetryEvent)) Option {
+	return func(o *options) {
+		o.retryListeners = append(o.retryListeners, listener)
+	}
+}
+
+// WithDeadLetter delivers the operation to the dead-letter sink when the retry functions give up,
+// together with the full attempt history and the final error. Clock timestamps the attempts and the failure,
+// system clock if nil.
+func WithDeadLetter(sink DeadLetterSink, clk Clock, operationID string, payload []byte) Option {
+	return func(o *options) {
+		o.deadLetter = &deadLetterConfig{
+			sink:        sink,
+			clk:         clk,
+			operationID: operationID,
+			payload:     payload,
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) onSuccess() {
+	if o.budget != nil {
+		o.budget.deposit()
+	}
+}
+
+func (o options) allowRetry() bool {
+	return o.budget == nil || o.budget.withdraw()
+}
+
+func (o options) onRetry(event RetryEvent) {
+	for _, listener := range o.retryListeners {
+		listener(event)
+	}
+}
+
+func (o options) onFailure(attempt int64, err error) {
+	for _, listener := range o.failureListeners {
+		listener(attempt, err)
+	}
+}
+
+// supplyOnce calls the operation once, guarded by the policy attempt limiter, bulkhead and circuit breaker.
+func supplyOnce[T any](ctx context.Context, slp Sleeper, o options, supply SupplyFunc[T]) (T, error) {
+	if o.limiter == nil {
+		return supplyGuarded(ctx, o, supply)
+	}
+	if delay := o.limiter.reserve(); delay > 0 {
+		slp.Sleep(delay)
+	}
+	res, err := supplyGuarded(ctx, o, supply)
+	o.limiter.observe(err)
+	return res, err
+}
+
+func supplyGuarded[T any](ctx context.Context, o options, supply SupplyFunc[T]) (T, error) {
+	var res T
+	if o.bulkhead != nil {
+		release, err := o.bulkhead.acquire(ctx, o.bulkheadName)
+		if err != nil {
+			return res, err
+		}
+		defer release()
+	}
+	if o.breaker == nil {
+		return supply()
+	}
+	generation, ok := o.breaker.allow()
+	if !ok {
+		return res, ErrCircuitOpen
+	}
+	res, err := supply()
+	o.breaker.record(generation, err == nil)
+	return res, err
+}
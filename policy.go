@@ -22,7 +22,10 @@
 
 package retry
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 const (
 	defaultInitialInterval       = time.Second
@@ -80,6 +83,11 @@ func (p BackOffPolicy) IsAttemptingIndefinitely() bool {
 	return p.maxAttempts == undefinedMaxAttempts
 }
 
+// Delay returns the delay before the next attempt, after the given attempt (starting from 1) failed.
+func (p BackOffPolicy) Delay(attempt int64) time.Duration {
+	return delay(p, attempt)
+}
+
 func (p BackOffPolicy) getInitialInterval() time.Duration {
 	return p.initialInterval
 }
@@ -117,6 +125,11 @@ func (p FixedDelayPolicy) IsAttemptingIndefinitely() bool {
 	return p.maxAttempts == undefinedMaxAttempts
 }
 
+// Delay returns the delay before the next attempt, after the given attempt (starting from 1) failed.
+func (p FixedDelayPolicy) Delay(attempt int64) time.Duration {
+	return delay(p, attempt)
+}
+
 func (p FixedDelayPolicy) getInitialInterval() time.Duration {
 	return p.interval
 }
@@ -132,3 +145,19 @@ func (p FixedDelayPolicy) getMaxAttempts() int64 {
 func (p FixedDelayPolicy) getBackOffCoefficient() float64 {
 	return fixedDelayBackOffCoefficient
 }
+
+// delay calculates the interval the retry functions wait for after the given attempt failed.
+func delay(p policy, attempt int64) time.Duration {
+	interval := p.getInitialInterval()
+	for i := int64(1); i < attempt; i++ {
+		next := calcNextInterval(interval, p.getMaxInterval(), p.getBackOffCoefficient())
+		if next < 0 {
+			return time.Duration(math.MaxInt64)
+		}
+		if next == interval {
+			return interval
+		}
+		interval = next
+	}
+	return interval
+}
@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultInitialRate    = float64(10)
+	defaultMinRate        = float64(0.5)
+	defaultMaxRate        = float64(100)
+	defaultDecreaseFactor = float64(0.7)
+	defaultIncreaseStep   = float64(1)
+)
+
+// attemptLimiter - policy limiting the rate of attempts, based on the observed outcomes.
+type attemptLimiter interface {
+	// reserve reserves the next attempt, returns the delay before the attempt is permitted.
+	reserve() time.Duration
+	// observe observes the attempt outcome.
+	observe(err error)
+}
+
+// AdaptivePolicy represents the policy which limits the rate of attempts, adapting it to the observed outcomes
+// (additive increase, multiplicative decrease). The rate is multiplied by the decrease factor on each throttling
+// error and increased by the increase step on each success, within the min and max rate bounds.
+//
+// Intervals and max attempts come from the underlying policy. AdaptivePolicy is safe for concurrent use
+// and is meant to be shared by all the calls to the rate limited API.
+type AdaptivePolicy struct {
+	policy         policy
+	clk            Clock
+	minRate        float64
+	maxRate        float64
+	decreaseFactor float64
+	increaseStep   float64
+	isThrottling   func(error) bool
+
+	mu         sync.Mutex
+	rate       float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+// Rate returns the current number of attempts allowed per second.
+func (p *AdaptivePolicy) Rate() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rate
+}
+
+func (p *AdaptivePolicy) reserve() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refill()
+	p.tokens--
+	if p.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-p.tokens / p.rate * float64(time.Second))
+}
+
+func (p *AdaptivePolicy) observe(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refill()
+	switch {
+	case err == nil:
+		p.rate = min(p.rate+p.increaseStep, p.maxRate)
+	case p.isThrottling(err):
+		p.rate = max(p.rate*p.decreaseFactor, p.minRate)
+	}
+}
+
+// refill adds tokens accumulated since the last refill at the current rate, up to one second worth of tokens.
+func (p *AdaptivePolicy) refill() {
+	now := p.clk.Now()
+	p.tokens = min(p.tokens+now.Sub(p.lastRefill).Seconds()*p.rate, max(p.rate, 1))
+	p.lastRefill = now
+}
+
+func (p *AdaptivePolicy) getInitialInterval() time.Duration {
+	return p.policy.getInitialInterval()
+}
+
+func (p *AdaptivePolicy) getMaxInterval() time.Duration {
+	return p.policy.getMaxInterval()
+}
+
+func (p *AdaptivePolicy) getMaxAttempts() int64 {
+	return p.policy.getMaxAttempts()
+}
+
+func (p *AdaptivePolicy) getBackOffCoefficient() float64 {
+	return p.policy.getBackOffCoefficient()
+}
+
+func attemptLimiterOf(p policy) attemptLimiter {
+	if c, ok := p.(PolicyConfig); ok {
+		p = c.p
+	}
+	limiter, _ := p.(attemptLimiter)
+	return limiter
+}
@@ -0,0 +1,139 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+func Test_Policy_Adaptive_ShouldDecreaseRateMultiplicativelyOnThrottling(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		Adaptive().
+		WithClock(clock.NewFakeClock()).
+		WithPolicy(retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()).
+		WithInitialRate(40).
+		WithMinRate(8).
+		WithDecreaseFactor(0.5).
+		Build()
+	fail := func() error { return assert.AnError }
+
+	err := retry.Run(context.Background(), noSleep(), fail, p)
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.InDelta(t, 8.0, p.Rate(), 0.001)
+}
+
+func Test_Policy_Adaptive_ShouldIncreaseRateAdditivelyOnSuccess(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		Adaptive().
+		WithClock(clock.NewFakeClock()).
+		WithInitialRate(10).
+		WithMaxRate(12).
+		WithIncreaseStep(1.5).
+		Build()
+	succeed := func() error { return nil }
+
+	require.NoError(t, retry.Run(context.Background(), noSleep(), succeed, p))
+	assert.InDelta(t, 11.5, p.Rate(), 0.001)
+
+	require.NoError(t, retry.Run(context.Background(), noSleep(), succeed, p))
+	assert.InDelta(t, 12.0, p.Rate(), 0.001)
+}
+
+func Test_Policy_Adaptive_ShouldKeepRateOnErrorsOtherThanThrottling(t *testing.T) {
+	t.Parallel()
+	errThrottled := errors.New("throttled")
+	p := retry.Policy().
+		Adaptive().
+		WithClock(clock.NewFakeClock()).
+		WithPolicy(retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build()).
+		WithInitialRate(10).
+		WithThrottlingClassifier(func(err error) bool { return errors.Is(err, errThrottled) }).
+		Build()
+	fail := func() error { return assert.AnError }
+
+	require.ErrorIs(t, retry.Run(context.Background(), noSleep(), fail, p), assert.AnError)
+	assert.InDelta(t, 10.0, p.Rate(), 0.001)
+}
+
+func Test_Policy_Adaptive_ShouldLimitAttemptRate(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().
+		Adaptive().
+		WithClock(clk).
+		WithPolicy(retry.Policy().FixedDelay().WithInterval(time.Nanosecond).WithMaxAttempts(int64(4)).Build()).
+		WithInitialRate(2).
+		WithMinRate(2).
+		WithMaxRate(2).
+		Build()
+	var sleeps []time.Duration
+	slp := retry.SleeperF(func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		clk.Advance(d)
+	})
+	fail := func() error { return assert.AnError }
+
+	require.ErrorIs(t, retry.Run(context.Background(), slp, fail, p), assert.AnError)
+
+	require.Len(t, sleeps, 5)
+	assert.Equal(t, []time.Duration{time.Nanosecond, time.Nanosecond}, sleeps[:2])
+	assert.InDelta(t, float64(500*time.Millisecond), float64(sleeps[2]), float64(time.Microsecond))
+	assert.Equal(t, time.Nanosecond, sleeps[3])
+	assert.InDelta(t, float64(500*time.Millisecond), float64(sleeps[4]), float64(time.Microsecond))
+}
+
+func Test_Policy_Adaptive_ShouldUseUnderlyingPolicyIntervals(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	p := retry.Policy().
+		Adaptive().
+		WithClock(clk).
+		WithPolicy(retry.Policy().
+			BackOff().
+			WithInitialInterval(time.Second).
+			WithMaxAttempts(int64(3)).
+			Build()).
+		Build()
+	var sleeps []time.Duration
+	slp := retry.SleeperF(func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		clk.Advance(d)
+	})
+	fail := func() error { return assert.AnError }
+
+	require.ErrorIs(t, retry.Run(context.Background(), slp, fail, p), assert.AnError)
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+}
@@ -23,6 +23,7 @@
 package retry_test
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -185,3 +186,33 @@ func Test_Policy_BackOff_BackOffCoefficient_WhenGreaterThanZero(t *testing.T) {
 	got := p.BackOffCoefficient()
 	assert.Equal(t, 0.1, got)
 }
+
+func Test_Policy_BackOff_Delay(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxInterval(time.Second).
+		WithBackOffCoefficient(2.0).
+		Build()
+	got := []time.Duration{p.Delay(1), p.Delay(2), p.Delay(3), p.Delay(4), p.Delay(5), p.Delay(100)}
+	assert.Equal(t, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}, got)
+}
+
+func Test_Policy_BackOff_Delay_WhenUnlimitedMaxInterval(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(time.Second).
+		WithMaxIntervalUnlimited().
+		Build()
+	assert.Equal(t, 8*time.Second, p.Delay(4))
+	assert.Equal(t, time.Duration(math.MaxInt64), p.Delay(100))
+}
@@ -0,0 +1,488 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	policyTypeBackOff    = "backoff"
+	policyTypeFixedDelay = "fixed"
+
+	unlimitedMaxIntervalText = "unlimited"
+	undefinedMaxAttemptsText = "indefinite"
+
+	textKeyInitialInterval    = "initial"
+	textKeyMaxInterval        = "max"
+	textKeyInterval           = "interval"
+	textKeyMaxAttempts        = "attempts"
+	textKeyBackOffCoefficient = "coefficient"
+)
+
+var (
+	// ErrUnknownPolicyType is returned when decoding a policy of an unsupported type.
+	ErrUnknownPolicyType = errors.New("unknown policy type")
+	// ErrInvalidPolicy is returned when a policy definition cannot be decoded.
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// PolicyConfig is a polymorphic holder of either BackOffPolicy or FixedDelayPolicy.
+//
+// It may be embedded in configuration structs and decoded from JSON (using the "type" discriminator)
+// or from text (e.g. "backoff(initial=1s,max=30s,attempts=5,coefficient=2)" or "fixed(interval=1s,attempts=3)").
+// Zero value represents the default BackOffPolicy.
+type PolicyConfig struct {
+	p policy
+}
+
+// NewPolicyConfig wraps the given policy into PolicyConfig.
+func NewPolicyConfig(p policy) PolicyConfig {
+	if c, ok := p.(PolicyConfig); ok {
+		return c
+	}
+	return PolicyConfig{p: p}
+}
+
+// ParsePolicy parses policy text representation,
+// e.g. "backoff(initial=1s,max=30s,attempts=5,coefficient=2)" or "fixed(interval=1s,attempts=3)".
+func ParsePolicy(text string) (PolicyConfig, error) {
+	var c PolicyConfig
+	if err := c.UnmarshalText([]byte(text)); err != nil {
+		return PolicyConfig{}, err
+	}
+	return c, nil
+}
+
+// BackOff returns the BackOffPolicy held by the config and true, or false if config holds other policy type.
+func (c PolicyConfig) BackOff() (BackOffPolicy, bool) {
+	p, ok := c.resolve().(BackOffPolicy)
+	return p, ok
+}
+
+// FixedDelay returns the FixedDelayPolicy held by the config and true, or false if config holds other policy type.
+func (c PolicyConfig) FixedDelay() (FixedDelayPolicy, bool) {
+	p, ok := c.resolve().(FixedDelayPolicy)
+	return p, ok
+}
+
+// MaxAttempts returns the maximum number of attempts of the policy held by the config.
+func (c PolicyConfig) MaxAttempts() int64 {
+	return c.getMaxAttempts()
+}
+
+// IsAttemptingIndefinitely returns true if the policy held by the config is attempting indefinitely.
+func (c PolicyConfig) IsAttemptingIndefinitely() bool {
+	return c.getMaxAttempts() == undefinedMaxAttempts
+}
+
+// Delay returns the delay before the next attempt, after the given attempt (starting from 1) failed.
+func (c PolicyConfig) Delay(attempt int64) time.Duration {
+	return delay(c.resolve(), attempt)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c PolicyConfig) MarshalJSON() ([]byte, error) {
+	spec, err := specOf(c.resolve())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(spec)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both JSON object and JSON string with policy text are accepted,
+// JSON null is a no-op.
+func (c *PolicyConfig) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+	spec, err := unmarshalPolicySpec(data)
+	if err != nil {
+		return err
+	}
+	p, err := spec.build()
+	if err != nil {
+		return err
+	}
+	c.p = p
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c PolicyConfig) MarshalText() ([]byte, error) {
+	spec, err := specOf(c.resolve())
+	if err != nil {
+		return nil, err
+	}
+	return []byte(spec.text()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *PolicyConfig) UnmarshalText(text []byte) error {
+	spec, err := parsePolicySpec(string(text))
+	if err != nil {
+		return err
+	}
+	p, err := spec.build()
+	if err != nil {
+		return err
+	}
+	c.p = p
+	return nil
+}
+
+func (c PolicyConfig) resolve() policy {
+	if c.p == nil {
+		return Policy().BackOff().Build()
+	}
+	return c.p
+}
+
+func (c PolicyConfig) getInitialInterval() time.Duration {
+	return c.resolve().getInitialInterval()
+}
+
+func (c PolicyConfig) getMaxInterval() time.Duration {
+	return c.resolve().getMaxInterval()
+}
+
+func (c PolicyConfig) getMaxAttempts() int64 {
+	return c.resolve().getMaxAttempts()
+}
+
+func (c PolicyConfig) getBackOffCoefficient() float64 {
+	return c.resolve().getBackOffCoefficient()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p BackOffPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(backOffSpec(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The "type" property is optional, but if present must be "backoff".
+// Policy text JSON string is accepted as well.
+func (p *BackOffPolicy) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+	spec, err := unmarshalPolicySpec(data)
+	if err != nil {
+		return err
+	}
+	return p.fromSpec(spec)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p BackOffPolicy) MarshalText() ([]byte, error) {
+	return []byte(backOffSpec(p).text()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *BackOffPolicy) UnmarshalText(text []byte) error {
+	spec, err := parsePolicySpec(string(text))
+	if err != nil {
+		return err
+	}
+	return p.fromSpec(spec)
+}
+
+func (p *BackOffPolicy) fromSpec(spec policySpec) error {
+	if spec.Type == "" {
+		spec.Type = policyTypeBackOff
+	}
+	if spec.Type != policyTypeBackOff {
+		return fmt.Errorf("%w: expected %q, got %q", ErrUnknownPolicyType, policyTypeBackOff, spec.Type)
+	}
+	built, err := spec.buildBackOff()
+	if err != nil {
+		return err
+	}
+	*p = built
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p FixedDelayPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fixedDelaySpec(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The "type" property is optional, but if present must be "fixed".
+// Policy text JSON string is accepted as well.
+func (p *FixedDelayPolicy) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+	spec, err := unmarshalPolicySpec(data)
+	if err != nil {
+		return err
+	}
+	return p.fromSpec(spec)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p FixedDelayPolicy) MarshalText() ([]byte, error) {
+	return []byte(fixedDelaySpec(p).text()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *FixedDelayPolicy) UnmarshalText(text []byte) error {
+	spec, err := parsePolicySpec(string(text))
+	if err != nil {
+		return err
+	}
+	return p.fromSpec(spec)
+}
+
+func (p *FixedDelayPolicy) fromSpec(spec policySpec) error {
+	if spec.Type == "" {
+		spec.Type = policyTypeFixedDelay
+	}
+	if spec.Type != policyTypeFixedDelay {
+		return fmt.Errorf("%w: expected %q, got %q", ErrUnknownPolicyType, policyTypeFixedDelay, spec.Type)
+	}
+	built, err := spec.buildFixedDelay()
+	if err != nil {
+		return err
+	}
+	*p = built
+	return nil
+}
+
+// policySpec - serialized form of a policy, shared by JSON and text representations.
+// Omitted values resolve to the builder defaults.
+type policySpec struct {
+	Type               string  `json:"type"`
+	InitialInterval    string  `json:"initialInterval,omitempty"`
+	MaxInterval        string  `json:"maxInterval,omitempty"`
+	Interval           string  `json:"interval,omitempty"`
+	MaxAttempts        int64   `json:"maxAttempts,omitempty"`
+	BackOffCoefficient float64 `json:"backOffCoefficient,omitempty"`
+}
+
+// unmarshalPolicySpec decodes JSON object or JSON string containing policy text.
+// Unknown JSON object properties are rejected, the same as unknown policy text arguments.
+func unmarshalPolicySpec(data []byte) (policySpec, error) {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return parsePolicySpec(text)
+	}
+	var spec policySpec
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return policySpec{}, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	return spec, nil
+}
+
+func isJSONNull(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
+
+func specOf(p policy) (policySpec, error) {
+	switch typed := p.(type) {
+	case BackOffPolicy:
+		return backOffSpec(typed), nil
+	case FixedDelayPolicy:
+		return fixedDelaySpec(typed), nil
+	default:
+		return policySpec{}, fmt.Errorf("%w: %T", ErrUnknownPolicyType, p)
+	}
+}
+
+func backOffSpec(p BackOffPolicy) policySpec {
+	return policySpec{
+		Type:               policyTypeBackOff,
+		InitialInterval:    formatInterval(p.initialInterval),
+		MaxInterval:        formatInterval(p.maxInterval),
+		MaxAttempts:        p.maxAttempts,
+		BackOffCoefficient: p.backOffCoefficient,
+	}
+}
+
+func fixedDelaySpec(p FixedDelayPolicy) policySpec {
+	return policySpec{
+		Type:        policyTypeFixedDelay,
+		Interval:    formatInterval(p.interval),
+		MaxAttempts: p.maxAttempts,
+	}
+}
+
+func (s policySpec) build() (policy, error) {
+	switch s.Type {
+	case policyTypeBackOff:
+		return s.buildBackOff()
+	case policyTypeFixedDelay:
+		return s.buildFixedDelay()
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicyType, s.Type)
+	}
+}
+
+func (s policySpec) buildBackOff() (BackOffPolicy, error) {
+	initialInterval, err := parseInterval(s.InitialInterval)
+	if err != nil {
+		return BackOffPolicy{}, err
+	}
+	maxInterval, err := parseInterval(s.MaxInterval)
+	if err != nil {
+		return BackOffPolicy{}, err
+	}
+	return Policy().
+		BackOff().
+		WithInitialInterval(initialInterval).
+		WithMaxInterval(maxInterval).
+		WithMaxAttempts(s.MaxAttempts).
+		WithBackOffCoefficient(s.BackOffCoefficient).
+		Build(), nil
+}
+
+func (s policySpec) buildFixedDelay() (FixedDelayPolicy, error) {
+	interval, err := parseInterval(s.Interval)
+	if err != nil {
+		return FixedDelayPolicy{}, err
+	}
+	return Policy().
+		FixedDelay().
+		WithInterval(interval).
+		WithMaxAttempts(s.MaxAttempts).
+		Build(), nil
+}
+
+// text returns policy text representation, e.g. "fixed(interval=1s,attempts=3)".
+func (s policySpec) text() string {
+	var args []string
+	if s.Type == policyTypeBackOff {
+		args = []string{
+			textKeyInitialInterval + "=" + s.InitialInterval,
+			textKeyMaxInterval + "=" + s.MaxInterval,
+			textKeyMaxAttempts + "=" + formatAttempts(s.MaxAttempts),
+			textKeyBackOffCoefficient + "=" + strconv.FormatFloat(s.BackOffCoefficient, 'g', -1, 64),
+		}
+	} else {
+		args = []string{
+			textKeyInterval + "=" + s.Interval,
+			textKeyMaxAttempts + "=" + formatAttempts(s.MaxAttempts),
+		}
+	}
+	return s.Type + "(" + strings.Join(args, ",") + ")"
+}
+
+// parsePolicySpec parses policy text representation, e.g. "backoff(initial=1s,attempts=5)".
+// Arguments are optional, "backoff" and "backoff()" both denote the default back off policy.
+func parsePolicySpec(text string) (policySpec, error) {
+	text = strings.TrimSpace(text)
+	name, args, hasArgs := strings.Cut(text, "(")
+	if hasArgs {
+		var closed bool
+		if args, closed = strings.CutSuffix(args, ")"); !closed {
+			return policySpec{}, fmt.Errorf("%w: missing closing parenthesis in %q", ErrInvalidPolicy, text)
+		}
+	}
+	spec := policySpec{Type: strings.TrimSpace(name)}
+	if spec.Type != policyTypeBackOff && spec.Type != policyTypeFixedDelay {
+		return policySpec{}, fmt.Errorf("%w: %q", ErrUnknownPolicyType, spec.Type)
+	}
+	if strings.TrimSpace(args) == "" {
+		return spec, nil
+	}
+	for _, arg := range strings.Split(args, ",") {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return policySpec{}, fmt.Errorf("%w: expected key=value, got %q", ErrInvalidPolicy, arg)
+		}
+		if err := spec.set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return policySpec{}, err
+		}
+	}
+	return spec, nil
+}
+
+func (s *policySpec) set(key, value string) error {
+	var err error
+	switch {
+	case key == textKeyMaxAttempts:
+		s.MaxAttempts, err = parseAttempts(value)
+		return err
+	case key == textKeyInitialInterval && s.Type == policyTypeBackOff:
+		s.InitialInterval = value
+	case key == textKeyMaxInterval && s.Type == policyTypeBackOff:
+		s.MaxInterval = value
+	case key == textKeyBackOffCoefficient && s.Type == policyTypeBackOff:
+		if s.BackOffCoefficient, err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%w: argument %q: %w", ErrInvalidPolicy, key, err)
+		}
+	case key == textKeyInterval && s.Type == policyTypeFixedDelay:
+		s.Interval = value
+	default:
+		return fmt.Errorf("%w: unknown %s policy argument %q", ErrInvalidPolicy, s.Type, key)
+	}
+	return nil
+}
+
+func formatInterval(d time.Duration) string {
+	if d == unlimitedMaxInterval {
+		return unlimitedMaxIntervalText
+	}
+	return d.String()
+}
+
+// parseInterval parses duration string, "unlimited" denotes unlimited interval and empty string the default one.
+func parseInterval(s string) (time.Duration, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case unlimitedMaxIntervalText:
+		return unlimitedMaxInterval, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	return d, nil
+}
+
+func formatAttempts(attempts int64) string {
+	if attempts == undefinedMaxAttempts {
+		return undefinedMaxAttemptsText
+	}
+	return strconv.FormatInt(attempts, 10)
+}
+
+// parseAttempts parses number of attempts, "indefinite" denotes no attempts limit.
+func parseAttempts(s string) (int64, error) {
+	if s == undefinedMaxAttemptsText {
+		return undefinedMaxAttempts, nil
+	}
+	attempts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	return attempts, nil
+}
@@ -0,0 +1,265 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+)
+
+func Test_Policy_BackOff_JSON_RoundTrip(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(1500 * time.Millisecond).
+		WithMaxIntervalUnlimited().
+		WithMaxAttempts(int64(5)).
+		WithBackOffCoefficient(1.5).
+		Build()
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"type":"backoff","initialInterval":"1.5s","maxInterval":"unlimited","maxAttempts":5,"backOffCoefficient":1.5}`,
+		string(data))
+
+	var got retry.BackOffPolicy
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, p, got)
+}
+
+func Test_Policy_BackOff_JSON_ShouldResolveDefaultsForMissingProperties(t *testing.T) {
+	t.Parallel()
+	var got retry.BackOffPolicy
+	require.NoError(t, json.Unmarshal([]byte(`{"initialInterval":"200ms"}`), &got))
+	assert.Equal(t, retry.Policy().BackOff().WithInitialInterval(200*time.Millisecond).Build(), got)
+}
+
+func Test_Policy_BackOff_JSON_ShouldRejectOtherPolicyType(t *testing.T) {
+	t.Parallel()
+	var got retry.BackOffPolicy
+	err := json.Unmarshal([]byte(`{"type":"fixed","interval":"1s"}`), &got)
+	assert.ErrorIs(t, err, retry.ErrUnknownPolicyType)
+}
+
+func Test_Policy_BackOff_JSON_ShouldRejectInvalidDuration(t *testing.T) {
+	t.Parallel()
+	var got retry.BackOffPolicy
+	err := json.Unmarshal([]byte(`{"initialInterval":"soon"}`), &got)
+	assert.ErrorIs(t, err, retry.ErrInvalidPolicy)
+}
+
+func Test_Policy_FixedDelay_JSON_RoundTrip(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		FixedDelay().
+		WithInterval(250 * time.Millisecond).
+		WithMaxAttemptsIndefinite().
+		Build()
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"fixed","interval":"250ms","maxAttempts":-1}`, string(data))
+
+	var got retry.FixedDelayPolicy
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, p, got)
+}
+
+func Test_Policy_BackOff_Text_RoundTrip(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(time.Second).
+		WithMaxInterval(30 * time.Second).
+		WithMaxAttemptsIndefinite().
+		WithBackOffCoefficient(2.5).
+		Build()
+
+	text, err := p.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "backoff(initial=1s,max=30s,attempts=indefinite,coefficient=2.5)", string(text))
+
+	var got retry.BackOffPolicy
+	require.NoError(t, got.UnmarshalText(text))
+	assert.Equal(t, p, got)
+}
+
+func Test_Policy_FixedDelay_Text_RoundTrip(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		FixedDelay().
+		WithInterval(2 * time.Second).
+		WithMaxAttempts(int64(4)).
+		Build()
+
+	text, err := p.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "fixed(interval=2s,attempts=4)", string(text))
+
+	var got retry.FixedDelayPolicy
+	require.NoError(t, got.UnmarshalText(text))
+	assert.Equal(t, p, got)
+}
+
+func Test_ParsePolicy_ShouldParseBackOff(t *testing.T) {
+	t.Parallel()
+	c, err := retry.ParsePolicy(" backoff( initial=1s, max=30s, attempts=5 ) ")
+	require.NoError(t, err)
+
+	got, ok := c.BackOff()
+	require.True(t, ok)
+	assert.Equal(t, retry.Policy().
+		BackOff().
+		WithInitialInterval(time.Second).
+		WithMaxInterval(30*time.Second).
+		WithMaxAttempts(int64(5)).
+		Build(), got)
+	_, ok = c.FixedDelay()
+	assert.False(t, ok)
+}
+
+func Test_ParsePolicy_ShouldParsePolicyWithoutArguments(t *testing.T) {
+	t.Parallel()
+	c, err := retry.ParsePolicy("fixed")
+	require.NoError(t, err)
+
+	got, ok := c.FixedDelay()
+	require.True(t, ok)
+	assert.Equal(t, retry.Policy().FixedDelay().Build(), got)
+}
+
+func Test_ParsePolicy_ShouldRejectInvalidText(t *testing.T) {
+	t.Parallel()
+	tests := map[string]error{
+		"exponential(initial=1s)":  retry.ErrUnknownPolicyType,
+		"backoff(initial=1s":       retry.ErrInvalidPolicy,
+		"backoff(initial)":         retry.ErrInvalidPolicy,
+		"backoff(interval=1s)":     retry.ErrInvalidPolicy,
+		"fixed(attempts=many)":     retry.ErrInvalidPolicy,
+		"backoff(coefficient=two)": retry.ErrInvalidPolicy,
+	}
+	for text, wantErr := range tests {
+		_, err := retry.ParsePolicy(text)
+		assert.ErrorIs(t, err, wantErr, text)
+	}
+}
+
+func Test_PolicyConfig_JSON_ShouldDecodeSpecifiedPolicyType(t *testing.T) {
+	t.Parallel()
+	var cfg struct {
+		Payments retry.PolicyConfig `json:"payments"`
+		Uploads  retry.PolicyConfig `json:"uploads"`
+		Default  retry.PolicyConfig `json:"default"`
+	}
+	data := `{
+		"payments": {"type": "backoff", "initialInterval": "100ms", "maxAttempts": 10},
+		"uploads": {"type": "fixed", "interval": "1.5s"}
+	}`
+	require.NoError(t, json.Unmarshal([]byte(data), &cfg))
+
+	payments, ok := cfg.Payments.BackOff()
+	require.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, payments.InitialInterval())
+	assert.Equal(t, int64(10), payments.MaxAttempts())
+
+	uploads, ok := cfg.Uploads.FixedDelay()
+	require.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, uploads.Interval())
+
+	defaults, ok := cfg.Default.BackOff()
+	require.True(t, ok)
+	assert.Equal(t, retry.Policy().BackOff().Build(), defaults)
+}
+
+func Test_PolicyConfig_JSON_ShouldRejectUnknownType(t *testing.T) {
+	t.Parallel()
+	var c retry.PolicyConfig
+	err := json.Unmarshal([]byte(`{"type":"random"}`), &c)
+	assert.ErrorIs(t, err, retry.ErrUnknownPolicyType)
+}
+
+func Test_PolicyConfig_JSON_ShouldRejectUnknownProperties(t *testing.T) {
+	t.Parallel()
+	var c retry.PolicyConfig
+	err := json.Unmarshal([]byte(`{"type":"backoff","maxAtempts":9}`), &c)
+	assert.ErrorIs(t, err, retry.ErrInvalidPolicy)
+}
+
+func Test_PolicyConfig_JSON_ShouldIgnoreNull(t *testing.T) {
+	t.Parallel()
+	var cfg struct {
+		Policy  retry.PolicyConfig     `json:"policy"`
+		BackOff retry.BackOffPolicy    `json:"backOff"`
+		Fixed   retry.FixedDelayPolicy `json:"fixed"`
+	}
+	cfg.Policy = retry.NewPolicyConfig(retry.Policy().FixedDelay().Build())
+
+	require.NoError(t, json.Unmarshal([]byte(`{"policy":null,"backOff":null,"fixed":null}`), &cfg))
+
+	_, ok := cfg.Policy.FixedDelay()
+	assert.True(t, ok)
+	assert.Equal(t, retry.BackOffPolicy{}, cfg.BackOff)
+	assert.Equal(t, retry.FixedDelayPolicy{}, cfg.Fixed)
+}
+
+func Test_PolicyConfig_JSON_RoundTrip(t *testing.T) {
+	t.Parallel()
+	c := retry.NewPolicyConfig(retry.Policy().FixedDelay().WithInterval(time.Minute).Build())
+
+	data, err := json.Marshal(c)
+	require.NoError(t, err)
+
+	var got retry.PolicyConfig
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, c, got)
+}
+
+func Test_PolicyConfig_JSON_ShouldDecodePolicyText(t *testing.T) {
+	t.Parallel()
+	var policies map[string]retry.PolicyConfig
+	data := `{"s3-upload": "fixed(interval=5s,attempts=indefinite)"}`
+	require.NoError(t, json.Unmarshal([]byte(data), &policies))
+
+	got, ok := policies["s3-upload"].FixedDelay()
+	require.True(t, ok)
+	assert.True(t, got.IsAttemptingIndefinitely())
+	assert.Equal(t, 5*time.Second, got.Interval())
+}
+
+func Test_PolicyConfig_ShouldDelegateToHeldPolicy(t *testing.T) {
+	t.Parallel()
+	c, err := retry.ParsePolicy("backoff(initial=1s,max=5s,attempts=4,coefficient=3)")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), c.MaxAttempts())
+	assert.False(t, c.IsAttemptingIndefinitely())
+	assert.Equal(t, []time.Duration{time.Second, 3 * time.Second, 5 * time.Second},
+		[]time.Duration{c.Delay(1), c.Delay(2), c.Delay(3)})
+	assert.True(t, retry.PolicyConfig{}.Delay(1) > 0)
+}
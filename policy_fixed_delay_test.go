@@ -107,3 +107,13 @@ func Test_Policy_FixedDelay_MaxAttempts_WhenGreaterThanZero(t *testing.T) {
 	got := p.MaxAttempts()
 	assert.Equal(t, int64(5), got)
 }
+
+func Test_Policy_FixedDelay_Delay(t *testing.T) {
+	t.Parallel()
+	p := retry.Policy().
+		FixedDelay().
+		WithInterval(5 * time.Second).
+		Build()
+	assert.Equal(t, 5*time.Second, p.Delay(1))
+	assert.Equal(t, 5*time.Second, p.Delay(10))
+}
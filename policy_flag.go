@@ -0,0 +1,153 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	settingInitialInterval    = "initial"
+	settingMaxInterval        = "max-interval"
+	settingInterval           = "interval"
+	settingMaxAttempts        = "max-attempts"
+	settingBackOffCoefficient = "coefficient"
+)
+
+// BackOffFlags registers back off policy settings on the flag set, each flag name prefixed with the given prefix,
+// e.g. "db-retry" prefix registers -db-retry-initial, -db-retry-max-interval, -db-retry-max-attempts
+// and -db-retry-coefficient flags.
+//
+// Returned builder is populated when the flag set is parsed. Flags which are not set resolve to the builder defaults.
+func BackOffFlags(fs *flag.FlagSet, prefix string) *BackOffPolicyBuilder {
+	b := &BackOffPolicyBuilder{}
+	fs.Var((*intervalValue)(&b.initialInterval), settingName(prefix, settingInitialInterval),
+		"initial interval between retries (0 for default 1s)")
+	fs.Var((*intervalValue)(&b.maxInterval), settingName(prefix, settingMaxInterval),
+		"maximum interval between retries, 'unlimited' for no limit (0 for default 30s)")
+	fs.Var((*attemptsValue)(&b.maxAttempts), settingName(prefix, settingMaxAttempts),
+		"maximum number of attempts, 'indefinite' for no limit (0 for default 3)")
+	fs.Float64Var(&b.backOffCoefficient, settingName(prefix, settingBackOffCoefficient), 0,
+		"back off delay coefficient (0 for default 2)")
+	return b
+}
+
+// FixedDelayFlags registers fixed delay policy settings on the flag set, each flag name prefixed with the given prefix,
+// e.g. "db-retry" prefix registers -db-retry-interval and -db-retry-max-attempts flags.
+//
+// Returned builder is populated when the flag set is parsed. Flags which are not set resolve to the builder defaults.
+func FixedDelayFlags(fs *flag.FlagSet, prefix string) *FixedDelayPolicyBuilder {
+	b := &FixedDelayPolicyBuilder{}
+	fs.Var((*intervalValue)(&b.interval), settingName(prefix, settingInterval),
+		"interval between retries (0 for default 1s)")
+	fs.Var((*attemptsValue)(&b.maxAttempts), settingName(prefix, settingMaxAttempts),
+		"maximum number of attempts, 'indefinite' for no limit (0 for default 3)")
+	return b
+}
+
+// BackOffFromEnv builds back off policy from environment variables named after BackOffFlags flags,
+// e.g. "db-retry" prefix reads DB_RETRY_INITIAL, DB_RETRY_MAX_INTERVAL, DB_RETRY_MAX_ATTEMPTS
+// and DB_RETRY_COEFFICIENT variables.
+func BackOffFromEnv(prefix string) (BackOffPolicy, error) {
+	fs := flag.NewFlagSet(prefix, flag.ContinueOnError)
+	b := BackOffFlags(fs, prefix)
+	if err := setFromEnv(fs); err != nil {
+		return BackOffPolicy{}, err
+	}
+	return b.Build(), nil
+}
+
+// FixedDelayFromEnv builds fixed delay policy from environment variables named after FixedDelayFlags flags,
+// e.g. "db-retry" prefix reads DB_RETRY_INTERVAL and DB_RETRY_MAX_ATTEMPTS variables.
+func FixedDelayFromEnv(prefix string) (FixedDelayPolicy, error) {
+	fs := flag.NewFlagSet(prefix, flag.ContinueOnError)
+	b := FixedDelayFlags(fs, prefix)
+	if err := setFromEnv(fs); err != nil {
+		return FixedDelayPolicy{}, err
+	}
+	return b.Build(), nil
+}
+
+func settingName(prefix, setting string) string {
+	if prefix == "" {
+		return setting
+	}
+	return prefix + "-" + setting
+}
+
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// setFromEnv sets each flag registered on the flag set from the corresponding environment variable, if present.
+func setFromEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("%s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// intervalValue - flag.Value accepting duration strings and "unlimited".
+type intervalValue time.Duration
+
+func (v *intervalValue) String() string {
+	return formatInterval(time.Duration(*v))
+}
+
+func (v *intervalValue) Set(s string) error {
+	d, err := parseInterval(s)
+	if err != nil {
+		return err
+	}
+	*v = intervalValue(d)
+	return nil
+}
+
+// attemptsValue - flag.Value accepting number of attempts and "indefinite".
+type attemptsValue int64
+
+func (v *attemptsValue) String() string {
+	return formatAttempts(int64(*v))
+}
+
+func (v *attemptsValue) Set(s string) error {
+	attempts, err := parseAttempts(s)
+	if err != nil {
+		return err
+	}
+	*v = attemptsValue(attempts)
+	return nil
+}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+)
+
+func Test_BackOffFlags_ShouldBuildPolicyFromFlags(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	b := retry.BackOffFlags(fs, "db-retry")
+
+	err := fs.Parse([]string{
+		"-db-retry-initial", "200ms",
+		"-db-retry-max-interval", "unlimited",
+		"-db-retry-max-attempts", "7",
+		"-db-retry-coefficient", "1.5",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, retry.Policy().
+		BackOff().
+		WithInitialInterval(200*time.Millisecond).
+		WithMaxIntervalUnlimited().
+		WithMaxAttempts(int64(7)).
+		WithBackOffCoefficient(1.5).
+		Build(), b.Build())
+}
+
+func Test_BackOffFlags_ShouldResolveDefaultsWhenNotSet(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	b := retry.BackOffFlags(fs, "db-retry")
+
+	require.NoError(t, fs.Parse(nil))
+
+	assert.Equal(t, retry.Policy().BackOff().Build(), b.Build())
+}
+
+func Test_BackOffFlags_ShouldRejectInvalidValue(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	retry.BackOffFlags(fs, "db-retry")
+
+	err := fs.Parse([]string{"-db-retry-max-attempts", "many"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "-db-retry-max-attempts")
+}
+
+func Test_FixedDelayFlags_ShouldBuildPolicyFromFlags(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	b := retry.FixedDelayFlags(fs, "s3-retry")
+
+	err := fs.Parse([]string{"-s3-retry-interval=3s", "-s3-retry-max-attempts=indefinite"})
+	require.NoError(t, err)
+
+	assert.Equal(t, retry.Policy().
+		FixedDelay().
+		WithInterval(3*time.Second).
+		WithMaxAttemptsIndefinite().
+		Build(), b.Build())
+}
+
+func Test_FixedDelayFlags_ShouldRegisterFlagsWithoutPrefix(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	retry.FixedDelayFlags(fs, "")
+
+	assert.NotNil(t, fs.Lookup("interval"))
+	assert.NotNil(t, fs.Lookup("max-attempts"))
+}
+
+//nolint:paralleltest // uses t.Setenv
+func Test_BackOffFromEnv_ShouldBuildPolicyFromEnvironment(t *testing.T) {
+	t.Setenv("DB_RETRY_INITIAL", "1.5s")
+	t.Setenv("DB_RETRY_MAX_INTERVAL", "1m")
+	t.Setenv("DB_RETRY_MAX_ATTEMPTS", "indefinite")
+
+	got, err := retry.BackOffFromEnv("db-retry")
+
+	require.NoError(t, err)
+	assert.Equal(t, retry.Policy().
+		BackOff().
+		WithInitialInterval(1500*time.Millisecond).
+		WithMaxInterval(time.Minute).
+		WithMaxAttemptsIndefinite().
+		Build(), got)
+}
+
+//nolint:paralleltest // uses t.Setenv
+func Test_BackOffFromEnv_ShouldReportInvalidVariable(t *testing.T) {
+	t.Setenv("PAYMENTS_RETRY_INITIAL", "soon")
+
+	_, err := retry.BackOffFromEnv("payments-retry")
+
+	require.ErrorIs(t, err, retry.ErrInvalidPolicy)
+	assert.Contains(t, err.Error(), "PAYMENTS_RETRY_INITIAL")
+}
+
+//nolint:paralleltest // uses t.Setenv
+func Test_FixedDelayFromEnv_ShouldBuildPolicyFromEnvironment(t *testing.T) {
+	t.Setenv("S3_RETRY_INTERVAL", "250ms")
+	t.Setenv("S3_RETRY_MAX_ATTEMPTS", "5")
+
+	got, err := retry.FixedDelayFromEnv("s3-retry")
+
+	require.NoError(t, err)
+	assert.Equal(t, retry.Policy().
+		FixedDelay().
+		WithInterval(250*time.Millisecond).
+		WithMaxAttempts(int64(5)).
+		Build(), got)
+}
@@ -0,0 +1,184 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Registry maps policy names (e.g. "payments-api") to policies, so the retry behaviour may be changed at runtime.
+// Registry is safe for concurrent use. Zero value is an empty registry ready to use.
+type Registry struct {
+	mu       sync.Mutex
+	policies atomic.Pointer[map[string]PolicyConfig]
+}
+
+// NewRegistry creates registry with the given policies.
+func NewRegistry(policies map[string]PolicyConfig) *Registry {
+	r := &Registry{}
+	r.Replace(policies)
+	return r
+}
+
+// Lookup returns the policy registered under the given name.
+func (r *Registry) Lookup(name string) (PolicyConfig, bool) {
+	policies := r.policies.Load()
+	if policies == nil {
+		return PolicyConfig{}, false
+	}
+	p, ok := (*policies)[name]
+	return p, ok
+}
+
+// Register registers the policy under the given name, replacing the previous one (if any).
+func (r *Registry) Register(name string, p policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	policies := map[string]PolicyConfig{}
+	if current := r.policies.Load(); current != nil {
+		for n, cp := range *current {
+			policies[n] = cp
+		}
+	}
+	policies[name] = NewPolicyConfig(p)
+	r.policies.Store(&policies)
+}
+
+// Replace atomically replaces all registered policies with the given ones.
+func (r *Registry) Replace(policies map[string]PolicyConfig) {
+	replaced := make(map[string]PolicyConfig, len(policies))
+	for n, p := range policies {
+		replaced[n] = p
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies.Store(&replaced)
+}
+
+// LoadJSON replaces all registered policies with the ones decoded from JSON object mapping names to policies, e.g.
+//
+//	{"payments-api": {"type": "backoff", "initialInterval": "100ms"}, "s3-upload": "fixed(interval=1s)"}
+//
+// Registered policies are left intact if decoding fails.
+func (r *Registry) LoadJSON(rd io.Reader) error {
+	var policies map[string]PolicyConfig
+	if err := json.NewDecoder(rd).Decode(&policies); err != nil {
+		return fmt.Errorf("decoding policies: %w", err)
+	}
+	r.Replace(policies)
+	return nil
+}
+
+// LoadFile replaces all registered policies with the ones read from JSON file. See LoadJSON for the file format.
+func (r *Registry) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening policies file: %w", err)
+	}
+	defer f.Close()
+	return r.LoadJSON(f)
+}
+
+// WatchFile loads policies from JSON file and keeps reloading them whenever the file changes,
+// until the context is canceled. File is checked for changes every interval, using slp to wait between checks.
+//
+// Initial load error is returned, subsequent reload errors are reported to onError (if not nil),
+// leaving the previously loaded policies intact.
+func (r *Registry) WatchFile(
+	ctx context.Context, slp Sleeper, path string, interval time.Duration, onError func(error),
+) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("watching policies file: %w", err)
+	}
+	if err = r.LoadFile(path); err != nil {
+		return err
+	}
+	go r.watchFile(ctx, slp, path, interval, stat, onError)
+	return nil
+}
+
+func (r *Registry) watchFile(
+	ctx context.Context, slp Sleeper, path string, interval time.Duration, last os.FileInfo, onError func(error),
+) {
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+	for {
+		slp.Sleep(interval)
+		if ctx.Err() != nil {
+			return
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			report(fmt.Errorf("watching policies file: %w", err))
+			continue
+		}
+		if stat.ModTime().Equal(last.ModTime()) && stat.Size() == last.Size() {
+			continue
+		}
+		last = stat
+		if err = r.LoadFile(path); err != nil {
+			report(err)
+		}
+	}
+}
+
+// RunNamed runs the operation using the policy registered under the given name,
+// returns PolicyNotFoundError if there is no such policy.
+// The policy is looked up once per call, so the policy replacement does not affect already running retries.
+func RunNamed(ctx context.Context, slp Sleeper, run RunFunc, r *Registry, name string, opts ...Option) error {
+	return returnErrOnly(SupplyNamed(ctx, slp, runFuncToSupplyFunc(run), r, name, opts...))
+}
+
+// SupplyNamed supplies the result using the policy registered under the given name,
+// returns PolicyNotFoundError if there is no such policy.
+// The policy is looked up once per call, so the policy replacement does not affect already running retries.
+func SupplyNamed[T any](
+	ctx context.Context, slp Sleeper, supply SupplyFunc[T], r *Registry, name string, opts ...Option,
+) (T, error) {
+	p, ok := r.Lookup(name)
+	if !ok {
+		var res T
+		return res, PolicyNotFoundError{Name: name}
+	}
+	return Supply(ctx, slp, supply, p, opts...)
+}
+
+// PolicyNotFoundError is returned when there is no policy registered under the given name.
+type PolicyNotFoundError struct {
+	Name string
+}
+
+func (e PolicyNotFoundError) Error() string {
+	return fmt.Sprintf("Policy %q not found", e.Name)
+}
@@ -0,0 +1,203 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+)
+
+func Test_Registry_ShouldLookupRegisteredPolicy(t *testing.T) {
+	t.Parallel()
+	var r retry.Registry
+	p := retry.Policy().FixedDelay().WithInterval(time.Minute).Build()
+
+	r.Register("s3-upload", p)
+
+	got, ok := r.Lookup("s3-upload")
+	require.True(t, ok)
+	fixedDelay, ok := got.FixedDelay()
+	require.True(t, ok)
+	assert.Equal(t, p, fixedDelay)
+	_, ok = r.Lookup("payments-api")
+	assert.False(t, ok)
+}
+
+func Test_Registry_ShouldReplaceAllPolicies(t *testing.T) {
+	t.Parallel()
+	r := retry.NewRegistry(map[string]retry.PolicyConfig{
+		"payments-api": retry.NewPolicyConfig(retry.Policy().BackOff().Build()),
+	})
+
+	r.Replace(map[string]retry.PolicyConfig{
+		"s3-upload": retry.NewPolicyConfig(retry.Policy().FixedDelay().Build()),
+	})
+
+	_, ok := r.Lookup("payments-api")
+	assert.False(t, ok)
+	_, ok = r.Lookup("s3-upload")
+	assert.True(t, ok)
+}
+
+func Test_Registry_ShouldBeSafeForConcurrentUse(t *testing.T) {
+	t.Parallel()
+	var r retry.Registry
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.Register("payments-api", retry.Policy().BackOff().WithMaxAttempts(int64(i+1)).Build())
+		}()
+		go func() {
+			defer wg.Done()
+			r.Lookup("payments-api")
+		}()
+	}
+	wg.Wait()
+
+	_, ok := r.Lookup("payments-api")
+	assert.True(t, ok)
+}
+
+func Test_Registry_LoadJSON_ShouldKeepPoliciesWhenInvalid(t *testing.T) {
+	t.Parallel()
+	var r retry.Registry
+	require.NoError(t, r.LoadJSON(strings.NewReader(`{"payments-api": {"type": "backoff"}}`)))
+
+	err := r.LoadJSON(strings.NewReader(`{"payments-api": {"type": "random"}}`))
+
+	require.ErrorIs(t, err, retry.ErrUnknownPolicyType)
+	_, ok := r.Lookup("payments-api")
+	assert.True(t, ok)
+}
+
+func Test_Registry_WatchFile_ShouldReloadChangedFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "policies.json")
+	writeFile(t, path, `{"payments-api": "fixed(interval=1s)"}`, time.Now().Add(-time.Hour))
+
+	var r retry.Registry
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	slp := retry.SleeperF(func(time.Duration) { time.Sleep(5 * time.Millisecond) })
+	require.NoError(t, r.WatchFile(ctx, slp, path, time.Second, func(err error) { errs <- err }))
+
+	got, _ := r.Lookup("payments-api")
+	_, ok := got.FixedDelay()
+	require.True(t, ok)
+
+	writeFile(t, path, `{"payments-api": "backoff(initial=100ms)"}`, time.Now())
+
+	assert.Eventually(t, func() bool {
+		got, _ = r.Lookup("payments-api")
+		_, ok = got.BackOff()
+		return ok
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, errs)
+}
+
+func Test_Registry_WatchFile_ShouldReportReloadErrors(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "policies.json")
+	writeFile(t, path, `{"payments-api": "fixed(interval=1s)"}`, time.Now().Add(-time.Hour))
+
+	var r retry.Registry
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	slp := retry.SleeperF(func(time.Duration) { time.Sleep(5 * time.Millisecond) })
+	require.NoError(t, r.WatchFile(ctx, slp, path, time.Second, func(err error) { errs <- err }))
+
+	writeFile(t, path, `{"payments-api": "fixed(interval=soon)"}`, time.Now())
+
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, retry.ErrInvalidPolicy)
+	case <-time.After(time.Second):
+		require.Fail(t, "reload error not reported")
+	}
+	got, _ := r.Lookup("payments-api")
+	_, ok := got.FixedDelay()
+	assert.True(t, ok)
+}
+
+func Test_Registry_WatchFile_ShouldFailWhenFileDoesNotExist(t *testing.T) {
+	t.Parallel()
+	var r retry.Registry
+	slp := retry.SleeperF(func(time.Duration) {})
+
+	err := r.WatchFile(context.Background(), slp, filepath.Join(t.TempDir(), "missing.json"), time.Second, nil)
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_SupplyNamed_ShouldUseRegisteredPolicy(t *testing.T) {
+	t.Parallel()
+	var r retry.Registry
+	r.Register("payments-api", retry.Policy().FixedDelay().WithMaxAttempts(int64(2)).Build())
+	calls := 0
+	supplier := func() (int, error) {
+		calls++
+		return calls, assert.AnError
+	}
+
+	slp := retry.SleeperF(func(time.Duration) {})
+
+	res, err := retry.SupplyNamed(context.Background(), slp, supplier, &r, "payments-api")
+
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 2, res)
+	assert.Equal(t, 2, calls)
+}
+
+func Test_RunNamed_ShouldReturnErrorWhenPolicyNotFound(t *testing.T) {
+	t.Parallel()
+	var r retry.Registry
+	called := false
+	slp := retry.SleeperF(func(time.Duration) {})
+
+	err := retry.RunNamed(context.Background(), slp, func() error {
+		called = true
+		return nil
+	}, &r, "payments-api")
+
+	assert.Equal(t, retry.PolicyNotFoundError{Name: "payments-api"}, err)
+	assert.False(t, called)
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
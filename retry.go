@@ -24,6 +24,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -35,6 +36,12 @@ type (
 	Sleeper interface {
 		Sleep(duration time.Duration)
 	}
+
+	// Clock provides the current time and timers, compatible with clockwork.Clock.
+	Clock interface {
+		Now() time.Time
+		After(duration time.Duration) <-chan time.Time
+	}
 )
 
 type SleeperF func(duration time.Duration)
@@ -43,16 +50,42 @@ func (f SleeperF) Sleep(duration time.Duration) {
 	f(duration)
 }
 
-func Run(ctx context.Context, slp Sleeper, run RunFunc, p policy) error {
-	return returnErrOnly(Supply(ctx, slp, runFuncToSupplyFunc(run), p))
+// SystemClock returns Clock backed by the system time.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
 }
 
-func Supply[T any](ctx context.Context, slp Sleeper, supply SupplyFunc[T], p policy) (T, error) {
+func (systemClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+func Run(ctx context.Context, slp Sleeper, run RunFunc, p policy, opts ...Option) error {
+	return returnErrOnly(Supply(ctx, slp, runFuncToSupplyFunc(run), p, opts...))
+}
+
+func Supply[T any](ctx context.Context, slp Sleeper, supply SupplyFunc[T], p policy, opts ...Option) (T, error) {
+	o := newOptions(opts)
+	o.limiter = attemptLimiterOf(p)
+	letter := o.newDeadLetter()
+	res, err := supplyAttempts(ctx, slp, supply, p, o)
+	if err != nil && letter != nil {
+		err = o.deliverDeadLetter(ctx, letter, err)
+	}
+	return res, err
+}
+
+func supplyAttempts[T any](ctx context.Context, slp Sleeper, supply SupplyFunc[T], p policy, o options) (T, error) {
 	var res T
 	var err error
 	nextInterval := p.getInitialInterval()
 
-	for i := int64(0); i == int64(0) || i < p.getMaxAttempts(); {
+	for attempt := int64(1); ; attempt++ {
 		select {
 		case <-ctx.Done():
 			return res, DeadlineExceededError[T]{
@@ -62,18 +95,26 @@ func Supply[T any](ctx context.Context, slp Sleeper, supply SupplyFunc[T], p pol
 		default:
 		}
 
-		if res, err = supply(); err == nil {
+		if res, err = supplyOnce(ctx, slp, o, supply); err == nil {
+			o.onSuccess()
 			return res, nil
 		}
-		currInterval := nextInterval
+		o.onFailure(attempt, err)
+		if isPermanent(err) || !hasNextAttempt(attempt, p.getMaxAttempts()) {
+			return res, err
+		}
+		if !o.allowRetry() {
+			return res, fmt.Errorf("%w: %w", ErrBudgetExhausted, err)
+		}
+		currInterval := retryAfter(err, nextInterval)
 		nextInterval = calcNextInterval(nextInterval, p.getMaxInterval(), p.getBackOffCoefficient())
+		o.onRetry(RetryEvent{Attempt: attempt, Err: err, Delay: currInterval})
 		slp.Sleep(currInterval)
-		if p.getMaxAttempts() > 0 {
-			i++
-		}
 	}
+}
 
-	return res, err
+func hasNextAttempt(attempt, maxAttempts int64) bool {
+	return maxAttempts == undefinedMaxAttempts || attempt < maxAttempts
 }
 
 func calcNextInterval(current, maxInterval time.Duration, backOffCoefficient float64) time.Duration {
@@ -107,6 +148,64 @@ func returnErrOnly[T any](_ T, err error) error {
 	return err
 }
 
+// Permanent marks the error as permanent, so retry functions stop retrying and return it immediately.
+// Permanent returns nil if the error is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return PermanentError{Err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanentErr PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+// PermanentError is the error which is not retried, see Permanent.
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter marks the error with the minimum delay before the next attempt, e.g. requested by the server.
+// Retry functions wait for the longer of the delay and the policy interval. RetryAfter returns nil if the error is nil.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return RetryAfterError{Err: err, Delay: delay}
+}
+
+func retryAfter(err error, interval time.Duration) time.Duration {
+	var retryAfterErr RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return max(interval, retryAfterErr.Delay)
+	}
+	return interval
+}
+
+// RetryAfterError is the error requesting the minimum delay before the next attempt, see RetryAfter.
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
 type DeadlineExceededError[T any] struct {
 	Result T
 	Err    error
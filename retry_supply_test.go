@@ -30,6 +30,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retrytest"
 
 	clock "github.com/jonboulle/clockwork"
 )
@@ -37,20 +38,7 @@ import (
 func Test_Supply_ShouldReturnResultAfterRetries(t *testing.T) {
 	t.Parallel()
 
-	var startWG sync.WaitGroup
-	startWG.Add(1)
-	i := 0
-	supplier := func() (bool, error) {
-		if i == 0 {
-			startWG.Done()
-		}
-		i++
-		if i < 3 {
-			return false, assert.AnError
-		}
-		return true, nil
-	}
-
+	supplier := retrytest.FailTimes(2, assert.AnError, true)
 	backOffPolicy := retry.Policy().
 		BackOff().
 		WithInitialInterval(100 * time.Millisecond).
@@ -58,40 +46,20 @@ func Test_Supply_ShouldReturnResultAfterRetries(t *testing.T) {
 		WithBackOffCoefficient(2.0).
 		WithMaxAttempts(int64(3)).
 		Build()
+	rec := retrytest.NewSleeper()
 
-	clk := clock.NewFakeClockAt(time.Now())
-	start := clk.Now()
-
-	go func() {
-		startWG.Wait()
-		for clk.Since(start) <= 5*time.Second {
-			clk.Advance(100 * time.Millisecond)
-			time.Sleep(50 * time.Millisecond)
-		}
-	}()
-
-	res, err := retry.Supply(context.Background(), clk, supplier, backOffPolicy)
+	res, err := retry.Supply(context.Background(), rec, supplier.Supply, backOffPolicy)
 
 	assert.NoError(t, err)
 	assert.True(t, res)
+	retrytest.AssertCalls(t, supplier, 3)
+	retrytest.AssertDelays(t, rec, 100*time.Millisecond, 200*time.Millisecond)
 }
 
 func Test_Supply_ShouldReturnErrorWhenMaxAttemptsReached(t *testing.T) {
 	t.Parallel()
 
-	var startWG sync.WaitGroup
-	startWG.Add(1)
-	i := 0
-	supplier := func() (bool, error) {
-		if i == 0 {
-			startWG.Done()
-		}
-		i++
-		if i < 4 {
-			return false, assert.AnError
-		}
-		return true, nil
-	}
+	supplier := retrytest.FailTimes(3, assert.AnError, true)
 	backOffPolicy := retry.Policy().
 		BackOff().
 		WithInitialInterval(100 * time.Millisecond).
@@ -100,22 +68,12 @@ func Test_Supply_ShouldReturnErrorWhenMaxAttemptsReached(t *testing.T) {
 		WithMaxAttempts(int64(3)).
 		Build()
 
-	clk := clock.NewFakeClockAt(time.Now())
-	start := clk.Now()
-
-	go func() {
-		startWG.Wait()
-		for clk.Since(start) <= 5*time.Second {
-			clk.Advance(100 * time.Millisecond)
-			time.Sleep(50 * time.Millisecond)
-		}
-	}()
-
-	res, err := retry.Supply(context.Background(), clk, supplier, backOffPolicy)
+	res, err := retry.Supply(context.Background(), retrytest.NewSleeper(), supplier.Supply, backOffPolicy)
 
 	assert.Error(t, err)
 	assert.Equal(t, assert.AnError, err)
 	assert.False(t, res)
+	retrytest.AssertCalls(t, supplier, 3)
 }
 
 func Test_Supply_ShouldReturnErrorWhenContextCanceled(t *testing.T) {
@@ -166,24 +124,7 @@ func Test_Supply_ShouldReturnErrorWhenContextCanceled(t *testing.T) {
 func Test_Supply_ShouldRespectExponentialBackOffPolicy(t *testing.T) {
 	t.Parallel()
 
-	clk := clock.NewFakeClockAt(time.Now())
-
-	var startWG sync.WaitGroup
-	startWG.Add(1)
-	i := 0
-	tryTimes := make([]time.Time, 0)
-	supplier := func() (bool, error) {
-		tryTimes = append(tryTimes, clk.Now())
-		if i == 0 {
-			startWG.Done()
-		}
-		i++
-		if i < 5 {
-			return false, assert.AnError
-		}
-		return true, nil
-	}
-
+	supplier := retrytest.FailTimes(4, assert.AnError, true)
 	backOffPolicy := retry.Policy().
 		BackOff().
 		WithInitialInterval(100 * time.Millisecond).
@@ -191,82 +132,167 @@ func Test_Supply_ShouldRespectExponentialBackOffPolicy(t *testing.T) {
 		WithBackOffCoefficient(2.0).
 		WithMaxAttempts(int64(5)).
 		Build()
+	rec := retrytest.NewSleeper()
 
-	start := clk.Now()
+	res, err := retry.Supply(context.Background(), rec, supplier.Supply, backOffPolicy)
 
-	go func() {
-		startWG.Wait()
-		for clk.Since(start) <= 5*time.Second {
-			clk.Advance(100 * time.Millisecond)
-			time.Sleep(50 * time.Millisecond)
-		}
-	}()
+	assert.NoError(t, err)
+	assert.True(t, res)
+	retrytest.AssertCalls(t, supplier, 5)
+	retrytest.AssertDelays(t, rec,
+		100*time.Millisecond, 200*time.Millisecond, 400*time.Millisecond, 800*time.Millisecond)
+}
+
+func Test_Supply_ShouldRespectFixedDelayPolicy(t *testing.T) {
+	t.Parallel()
 
-	res, err := retry.Supply(context.Background(), clk, supplier, backOffPolicy)
+	supplier := retrytest.FailTimes(4, assert.AnError, true)
+	fixedDelayPolicy := retry.Policy().
+		FixedDelay().
+		WithInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(5)).
+		Build()
+	rec := retrytest.NewSleeper()
+
+	res, err := retry.Supply(context.Background(), rec, supplier.Supply, fixedDelayPolicy)
 
 	assert.NoError(t, err)
 	assert.True(t, res)
-	assert.Len(t, tryTimes, 5)
-	assert.Equal(t, start, tryTimes[0])
-	nextTry := start.Add(100 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[1])
-	nextTry = nextTry.Add(200 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[2])
-	nextTry = nextTry.Add(400 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[3])
-	nextTry = nextTry.Add(800 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[4])
+	retrytest.AssertCalls(t, supplier, 5)
+	retrytest.AssertDelays(t, rec,
+		100*time.Millisecond, 100*time.Millisecond, 100*time.Millisecond, 100*time.Millisecond)
 }
 
-func Test_Supply_ShouldRespectFixedDelayPolicy(t *testing.T) {
+func Test_Supply_ShouldNotSleepAfterLastAttempt(t *testing.T) {
 	t.Parallel()
 
-	clk := clock.NewFakeClockAt(time.Now())
+	calls := 0
+	supplier := func() (int, error) {
+		calls++
+		return calls, assert.AnError
+	}
+	fixedDelayPolicy := retry.Policy().
+		FixedDelay().
+		WithInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(3)).
+		Build()
+	var delays []time.Duration
+	sleeper := retry.SleeperF(func(d time.Duration) {
+		delays = append(delays, d)
+	})
+
+	res, err := retry.Supply(context.Background(), sleeper, supplier, fixedDelayPolicy)
+
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, 3, res)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond}, delays)
+}
+
+func Test_Supply_ShouldAttemptOnceWhenMaxAttemptsIsZero(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	supplier := func() (int, error) {
+		calls++
+		return calls, assert.AnError
+	}
+	var delays []time.Duration
+	sleeper := retry.SleeperF(func(d time.Duration) {
+		delays = append(delays, d)
+	})
+
+	res, err := retry.Supply(context.Background(), sleeper, supplier, retry.FixedDelayPolicy{})
+
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, 1, res)
+	assert.Empty(t, delays)
+}
+
+func Test_Supply_ShouldNotifyRetryListeners(t *testing.T) {
+	t.Parallel()
 
-	var startWG sync.WaitGroup
-	startWG.Add(1)
 	i := 0
-	tryTimes := make([]time.Time, 0)
-	supplier := func() (bool, error) {
-		tryTimes = append(tryTimes, clk.Now())
-		if i == 0 {
-			startWG.Done()
-		}
+	supplier := func() (int, error) {
 		i++
-		if i < 5 {
-			return false, assert.AnError
+		if i < 3 {
+			return i, assert.AnError
 		}
-		return true, nil
+		return i, nil
 	}
 
 	backOffPolicy := retry.Policy().
-		FixedDelay().
-		WithInterval(100 * time.Millisecond).
-		WithMaxAttempts(int64(5)).
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(3)).
 		Build()
 
-	start := clk.Now()
+	var events []retry.RetryEvent
+	listener := func(event retry.RetryEvent) {
+		events = append(events, event)
+	}
 
-	go func() {
-		startWG.Wait()
-		for clk.Since(start) <= 5*time.Second {
-			clk.Advance(100 * time.Millisecond)
-			time.Sleep(50 * time.Millisecond)
+	res, err := retry.Supply(context.Background(), retry.SleeperF(func(time.Duration) {}), supplier, backOffPolicy,
+		retry.WithRetryListener(listener))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, res)
+	assert.Equal(t, []retry.RetryEvent{
+		{Attempt: 1, Err: assert.AnError, Delay: 100 * time.Millisecond},
+		{Attempt: 2, Err: assert.AnError, Delay: 200 * time.Millisecond},
+	}, events)
+}
+
+func Test_Supply_ShouldNotRetryPermanentError(t *testing.T) {
+	t.Parallel()
+
+	i := 0
+	supplier := func() (int, error) {
+		i++
+		return i, retry.Permanent(assert.AnError)
+	}
+
+	backOffPolicy := retry.Policy().
+		BackOff().
+		WithMaxAttempts(int64(3)).
+		Build()
+
+	res, err := retry.Supply(context.Background(), retry.SleeperF(func(time.Duration) {}), supplier, backOffPolicy)
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, retry.PermanentError{Err: assert.AnError}, err)
+	assert.Equal(t, 1, res)
+}
+
+func Test_Supply_ShouldWaitAtLeastRetryAfterDelay(t *testing.T) {
+	t.Parallel()
+
+	i := 0
+	supplier := func() (int, error) {
+		i++
+		switch i {
+		case 1:
+			return i, retry.RetryAfter(assert.AnError, 5*time.Second)
+		case 2:
+			return i, retry.RetryAfter(assert.AnError, 50*time.Millisecond)
+		default:
+			return i, nil
 		}
-	}()
+	}
 
-	res, err := retry.Supply(context.Background(), clk, supplier, backOffPolicy)
+	backOffPolicy := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(3)).
+		Build()
+
+	var delays []time.Duration
+	sleeper := retry.SleeperF(func(d time.Duration) {
+		delays = append(delays, d)
+	})
+
+	res, err := retry.Supply(context.Background(), sleeper, supplier, backOffPolicy)
 
 	assert.NoError(t, err)
-	assert.True(t, res)
-	assert.Len(t, tryTimes, 5)
-	assert.Equal(t, start, tryTimes[0])
-	nextTry := start.Add(100 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[1])
-	nextTry = nextTry.Add(100 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[2])
-	nextTry = nextTry.Add(100 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[3])
-	nextTry = nextTry.Add(100 * time.Millisecond)
-	assert.Equal(t, nextTry, tryTimes[4])
+	assert.Equal(t, 3, res)
+	assert.Equal(t, []time.Duration{5 * time.Second, 200 * time.Millisecond}, delays)
 }
@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retryexec provides os/exec integration of the retry package.
+package retryexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+// Attempt is the outcome of a single command attempt.
+type Attempt struct {
+	// Stdout is the standard output of the attempt.
+	Stdout []byte
+	// Stderr is the standard error of the attempt.
+	Stderr []byte
+	// ExitCode is the exit code of the attempt, -1 if the command was not started or was terminated by a signal.
+	ExitCode int
+	// Signal is the signal which terminated the command, nil if the command exited.
+	Signal os.Signal
+	// Err is the error of the attempt, nil if the command succeeded.
+	Err error
+}
+
+// Started returns true if the command of the attempt was started.
+func (a Attempt) Started() bool {
+	return a.ExitCode != -1 || a.Signal != nil
+}
+
+// Classifier decides whether the failed attempt is retried.
+type Classifier func(a Attempt) bool
+
+// DefaultClassifier retries all the attempts of the commands which were started, but failed.
+func DefaultClassifier(a Attempt) bool {
+	return a.Started()
+}
+
+// ExitCodes returns Classifier retrying the attempts which exited with one of the exit codes.
+func ExitCodes(codes ...int) Classifier {
+	return func(a Attempt) bool {
+		return a.Signal == nil && slices.Contains(codes, a.ExitCode)
+	}
+}
+
+// Signals returns Classifier retrying the attempts terminated by one of the signals.
+func Signals(signals ...os.Signal) Classifier {
+	return func(a Attempt) bool {
+		return a.Signal != nil && slices.Contains(signals, a.Signal)
+	}
+}
+
+// Cmd is the command re-created and re-run on failure according to the policy.
+// exec.Cmd cannot be reused, so each attempt runs a new exec.Cmd created with exec.CommandContext.
+type Cmd struct {
+	// Name is the name of the command to run.
+	Name string
+	// Args are the command arguments.
+	Args []string
+	// Dir is the working directory of the command, see exec.Cmd.Dir.
+	Dir string
+	// Env is the environment of the command, see exec.Cmd.Env.
+	Env []string
+	// Stdin is the standard input passed to every attempt.
+	Stdin []byte
+	// Setup customizes exec.Cmd of every attempt, e.g. sets process attributes.
+	Setup func(cmd *exec.Cmd)
+	// Policy is the retry policy.
+	Policy retry.PolicyConfig
+	// Sleeper waits between attempts, time.Sleep if nil.
+	Sleeper retry.Sleeper
+	// Classifier decides which failed attempts are retried, DefaultClassifier if nil.
+	Classifier Classifier
+	// Options are passed to the retry function.
+	Options []retry.Option
+}
+
+// Command returns Cmd running the named command with the arguments, retried with the default policy.
+func Command(name string, args ...string) *Cmd {
+	return &Cmd{
+		Name: name,
+		Args: args,
+	}
+}
+
+// Run runs the command until it succeeds, the failure is not retryable, the attempts run out
+// or the context is done. Run returns all the attempts and the error of the last attempt.
+// The command of the running attempt is killed once the context is done.
+func (c *Cmd) Run(ctx context.Context) ([]Attempt, error) {
+	classify := c.Classifier
+	if classify == nil {
+		classify = DefaultClassifier
+	}
+	var attempts []Attempt
+	err := retry.Run(ctx, c.sleeper(), func() error {
+		a := c.attempt(ctx)
+		attempts = append(attempts, a)
+		if a.Err != nil && (!classify(a) || ctx.Err() != nil) {
+			return retry.Permanent(a.Err)
+		}
+		return a.Err
+	}, c.Policy, c.Options...)
+	var permanentErr retry.PermanentError
+	if errors.As(err, &permanentErr) {
+		return attempts, permanentErr.Err
+	}
+	return attempts, err
+}
+
+func (c *Cmd) attempt(ctx context.Context) Attempt {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Env = c.Env
+	cmd.Stdin = bytes.NewReader(c.Stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if c.Setup != nil {
+		c.Setup(cmd)
+	}
+	err := cmd.Run()
+	a := Attempt{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: -1,
+	}
+	if err != nil {
+		a.Err = fmt.Errorf("running %s: %w", c.Name, err)
+	}
+	if cmd.ProcessState != nil {
+		a.ExitCode = cmd.ProcessState.ExitCode()
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			a.Signal = status.Signal()
+		}
+	}
+	return a
+}
+
+func (c *Cmd) sleeper() retry.Sleeper {
+	if c.Sleeper == nil {
+		return retry.SleeperF(time.Sleep)
+	}
+	return c.Sleeper
+}
@@ -0,0 +1,164 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryexec_test
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retryexec"
+)
+
+func newCommand(script string, args ...string) *retryexec.Cmd {
+	cmd := retryexec.Command("sh", append([]string{"-c", script, "sh"}, args...)...)
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	cmd.Policy = retry.NewPolicyConfig(p)
+	cmd.Sleeper = retry.SleeperF(func(time.Duration) {})
+	return cmd
+}
+
+// failTimes is the script failing with the exit code for the given number of times, counting runs in the file.
+const failTimes = `echo x >> "$1"; n=$(wc -l < "$1"); echo "out $n"; echo "err $n" >&2; [ "$n" -gt "$2" ] || exit "$3"`
+
+func Test_Cmd_Run_ShouldRetryUntilCommandSucceeds(t *testing.T) {
+	t.Parallel()
+	counter := filepath.Join(t.TempDir(), "counter")
+	cmd := newCommand(failTimes, counter, "2", "1")
+
+	attempts, err := cmd.Run(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	for i, a := range attempts {
+		assert.Equal(t, "out "+strconv.Itoa(i+1)+"\n", string(a.Stdout))
+		assert.Equal(t, "err "+strconv.Itoa(i+1)+"\n", string(a.Stderr))
+	}
+	assert.Equal(t, 1, attempts[0].ExitCode)
+	assert.Error(t, attempts[0].Err)
+	assert.Equal(t, 0, attempts[2].ExitCode)
+	assert.NoError(t, attempts[2].Err)
+}
+
+func Test_Cmd_Run_ShouldReturnLastErrorWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	counter := filepath.Join(t.TempDir(), "counter")
+	cmd := newCommand(failTimes, counter, "9", "3")
+
+	attempts, err := cmd.Run(context.Background())
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Len(t, attempts, 3)
+}
+
+func Test_Cmd_Run_ShouldRetryOnlyChosenExitCodes(t *testing.T) {
+	t.Parallel()
+	counter := filepath.Join(t.TempDir(), "counter")
+	cmd := newCommand(failTimes, counter, "9", "4")
+	cmd.Classifier = retryexec.ExitCodes(3, 5)
+
+	attempts, err := cmd.Run(context.Background())
+
+	require.Error(t, err)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, 4, attempts[0].ExitCode)
+}
+
+func Test_Cmd_Run_ShouldClassifyBySignal(t *testing.T) {
+	t.Parallel()
+	cmd := newCommand(`kill -TERM $$`)
+	cmd.Classifier = retryexec.Signals(syscall.SIGTERM)
+
+	attempts, err := cmd.Run(context.Background())
+
+	require.Error(t, err)
+	require.Len(t, attempts, 3)
+	assert.Equal(t, syscall.SIGTERM, attempts[0].Signal)
+	assert.Equal(t, -1, attempts[0].ExitCode)
+	assert.True(t, attempts[0].Started())
+}
+
+func Test_Cmd_Run_ShouldNotRetryCommandsNotStarted(t *testing.T) {
+	t.Parallel()
+	cmd := retryexec.Command(filepath.Join(t.TempDir(), "missing"))
+	cmd.Sleeper = retry.SleeperF(func(time.Duration) {})
+
+	attempts, err := cmd.Run(context.Background())
+
+	require.Error(t, err)
+	require.Len(t, attempts, 1)
+	assert.False(t, attempts[0].Started())
+}
+
+func Test_Cmd_Run_ShouldPassStdinToEveryAttempt(t *testing.T) {
+	t.Parallel()
+	cmd := newCommand(`cat; exit 1`)
+	cmd.Stdin = []byte("payload")
+
+	attempts, err := cmd.Run(context.Background())
+
+	require.Error(t, err)
+	require.Len(t, attempts, 3)
+	for _, a := range attempts {
+		assert.Equal(t, "payload", string(a.Stdout))
+	}
+}
+
+func Test_Cmd_Run_ShouldKillCommandWhenContextIsDone(t *testing.T) {
+	t.Parallel()
+	cmd := newCommand(`exec sleep 10`)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+
+	attempts, err := cmd.Run(ctx)
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, syscall.SIGKILL, attempts[0].Signal)
+}
+
+func Test_Cmd_Run_ShouldSetupEveryAttempt(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cmd := newCommand(`pwd`)
+	cmd.Setup = func(c *exec.Cmd) {
+		c.Dir = dir
+	}
+
+	attempts, err := cmd.Run(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, dir+"\n", string(attempts[0].Stdout))
+}
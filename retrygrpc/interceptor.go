@@ -0,0 +1,244 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retrygrpc provides gRPC client interceptors retrying the calls according to the retry policy.
+package retrygrpc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AttemptMetadataKey is the outgoing metadata key carrying the attempt number (starting from 1).
+const AttemptMetadataKey = "retry-attempt"
+
+// DefaultRetryCodes are the status codes retried by default.
+func DefaultRetryCodes() []codes.Code {
+	return []codes.Code{codes.Unavailable, codes.ResourceExhausted}
+}
+
+// Interceptor provides client interceptors retrying the calls failing with the configured status codes.
+//
+// Each attempt sends its number in AttemptMetadataKey metadata. The policy may be overridden per call
+// with WithPolicy call option. Retries stop once the call context is done, waiting between attempts
+// never exceeds the call deadline.
+type Interceptor struct {
+	// Policy is the retry policy.
+	Policy retry.PolicyConfig
+	// Sleeper waits between attempts, time.Sleep if nil.
+	Sleeper retry.Sleeper
+	// RetryCodes are the status codes retried, DefaultRetryCodes if nil.
+	RetryCodes []codes.Code
+	// Options are passed to the retry function.
+	Options []retry.Option
+}
+
+// NewInterceptor creates Interceptor retrying the calls according to the policy.
+func NewInterceptor(p retry.PolicyConfig) *Interceptor {
+	return &Interceptor{Policy: p}
+}
+
+// PolicyCallOption is the call option overriding the Interceptor policy, see WithPolicy.
+type PolicyCallOption struct {
+	grpc.EmptyCallOption
+	Policy retry.PolicyConfig
+}
+
+// WithPolicy overrides the Interceptor policy for the call.
+func WithPolicy(p retry.PolicyConfig) PolicyCallOption {
+	return PolicyCallOption{Policy: p}
+}
+
+// Unary returns the unary client interceptor.
+func (i *Interceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		p, opts := i.callPolicy(opts)
+		attempt := 0
+		err := retry.Run(ctx, i.sleeper(ctx), func() error {
+			attempt++
+			return i.classify(invoker(withAttempt(ctx, attempt), method, req, reply, cc, opts...))
+		}, p, i.Options...)
+		return result(ctx, err)
+	}
+}
+
+// Stream returns the stream client interceptor. Establishing the stream is retried for all the streams.
+// Server streams are additionally retried when receiving the first message fails - the stream
+// is re-established and the request is sent again. Streams are not retried once the first message is received.
+func (i *Interceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		p, opts := i.callPolicy(opts)
+		s := &serverStream{
+			interceptor: i,
+			open: func(attempt int) (grpc.ClientStream, error) {
+				return streamer(withAttempt(ctx, attempt), desc, cc, method, opts...)
+			},
+			retry: func(run retry.RunFunc) error {
+				return result(ctx, retry.Run(ctx, i.sleeper(ctx), run, p, i.Options...))
+			},
+		}
+		stream, err := retry.Supply(ctx, i.sleeper(ctx), func() (grpc.ClientStream, error) {
+			s.attempt++
+			stream, err := s.open(s.attempt)
+			return stream, i.classify(err)
+		}, p, i.Options...)
+		if err = result(ctx, err); err != nil {
+			return nil, err
+		}
+		if desc.ClientStreams || !desc.ServerStreams {
+			return stream, nil
+		}
+		s.ClientStream = stream
+		return s, nil
+	}
+}
+
+func (i *Interceptor) callPolicy(opts []grpc.CallOption) (retry.PolicyConfig, []grpc.CallOption) {
+	p := i.Policy
+	callOpts := make([]grpc.CallOption, 0, len(opts))
+	for _, opt := range opts {
+		if policyOpt, ok := opt.(PolicyCallOption); ok {
+			p = policyOpt.Policy
+			continue
+		}
+		callOpts = append(callOpts, opt)
+	}
+	return p, callOpts
+}
+
+// classify marks the errors with the status codes not retried as permanent.
+func (i *Interceptor) classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	retryCodes := i.RetryCodes
+	if retryCodes == nil {
+		retryCodes = DefaultRetryCodes()
+	}
+	if !slices.Contains(retryCodes, status.Code(err)) {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+func (i *Interceptor) sleeper(ctx context.Context) retry.Sleeper {
+	slp := i.Sleeper
+	if slp == nil {
+		slp = retry.SleeperF(time.Sleep)
+	}
+	return retry.SleeperF(func(d time.Duration) {
+		if deadline, ok := ctx.Deadline(); ok {
+			d = max(min(d, time.Until(deadline)), 0)
+		}
+		slp.Sleep(d)
+	})
+}
+
+// result converts the retry function error to the status error.
+func result(ctx context.Context, err error) error {
+	var permanentErr retry.PermanentError
+	if errors.As(err, &permanentErr) {
+		return permanentErr.Err
+	}
+	var deadlineErr retry.DeadlineExceededError[any]
+	var streamDeadlineErr retry.DeadlineExceededError[grpc.ClientStream]
+	if errors.As(err, &deadlineErr) || errors.As(err, &streamDeadlineErr) {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return err
+}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, AttemptMetadataKey, strconv.Itoa(attempt))
+}
+
+// serverStream re-establishes the server stream when receiving the first message fails.
+type serverStream struct {
+	grpc.ClientStream
+	interceptor *Interceptor
+	open        func(attempt int) (grpc.ClientStream, error)
+	retry       func(run retry.RunFunc) error
+	attempt     int
+	request     any
+	closed      bool
+	received    bool
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	s.request = m
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *serverStream) CloseSend() error {
+	s.closed = true
+	return s.ClientStream.CloseSend()
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	if s.received {
+		return s.ClientStream.RecvMsg(m)
+	}
+	s.received = true
+	first := true
+	return s.retry(func() error {
+		if !first {
+			if err := s.reopen(); err != nil {
+				return err
+			}
+		}
+		first = false
+		return s.interceptor.classify(s.ClientStream.RecvMsg(m))
+	})
+}
+
+func (s *serverStream) reopen() error {
+	s.attempt++
+	stream, err := s.open(s.attempt)
+	if err != nil {
+		return s.interceptor.classify(err)
+	}
+	s.ClientStream = stream
+	if s.request != nil {
+		if err = stream.SendMsg(s.request); err != nil {
+			return s.interceptor.classify(err)
+		}
+	}
+	if s.closed {
+		return s.interceptor.classify(stream.CloseSend())
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrygrpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retrygrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const bufSize = 1 << 20
+
+// echoService is the test service, failing the calls with the scripted errors.
+type echoService interface {
+	echo(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error)
+	repeat(in *wrapperspb.StringValue, stream grpc.ServerStream) error
+}
+
+type echoServer struct {
+	mu       sync.Mutex
+	errs     []error
+	attempts []string
+}
+
+func (s *echoServer) next(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.attempts = append(s.attempts, md.Get(retrygrpc.AttemptMetadataKey)...)
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func (s *echoServer) recordedAttempts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.attempts...)
+}
+
+func (s *echoServer) echo(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	if err := s.next(ctx); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+func (s *echoServer) repeat(in *wrapperspb.StringValue, stream grpc.ServerStream) error {
+	if err := s.next(stream.Context()); err != nil {
+		return err
+	}
+	for range 3 {
+		if err := stream.SendMsg(in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*echoService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			return srv.(echoService).echo(ctx, in)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Repeat",
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			in := new(wrapperspb.StringValue)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			return srv.(echoService).repeat(in, stream)
+		},
+		ServerStreams: true,
+	}},
+}
+
+type sleepRecorder struct {
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (r *sleepRecorder) Sleep(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delays = append(r.delays, d)
+}
+
+func (r *sleepRecorder) get() []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.delays...)
+}
+
+func newInterceptor(slp retry.Sleeper) *retrygrpc.Interceptor {
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(3)).
+		Build()
+	interceptor := retrygrpc.NewInterceptor(retry.NewPolicyConfig(p))
+	interceptor.Sleeper = slp
+	return interceptor
+}
+
+func dial(t *testing.T, server *echoServer, interceptor *retrygrpc.Interceptor) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(bufSize)
+	srv := grpc.NewServer()
+	srv.RegisterService(&echoServiceDesc, server)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(interceptor.Unary()),
+		grpc.WithStreamInterceptor(interceptor.Stream()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+func echo(ctx context.Context, conn *grpc.ClientConn, opts ...grpc.CallOption) (string, error) {
+	out := new(wrapperspb.StringValue)
+	err := conn.Invoke(ctx, "/test.Echo/Echo", wrapperspb.String("hello"), out, opts...)
+	return out.GetValue(), err
+}
+
+func repeat(ctx context.Context, conn *grpc.ClientConn) ([]string, error) {
+	stream, err := conn.NewStream(ctx, &echoServiceDesc.Streams[0], "/test.Echo/Repeat")
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendMsg(wrapperspb.String("hello")); err != nil {
+		return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	var values []string
+	for {
+		out := new(wrapperspb.StringValue)
+		if err = stream.RecvMsg(out); errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, out.GetValue())
+	}
+}
+
+func Test_Unary_ShouldRetryRetryableCodes(t *testing.T) {
+	t.Parallel()
+	server := &echoServer{errs: []error{
+		status.Error(codes.Unavailable, "unavailable"),
+		status.Error(codes.ResourceExhausted, "exhausted"),
+	}}
+	slp := &sleepRecorder{}
+	conn := dial(t, server, newInterceptor(slp))
+
+	got, err := echo(context.Background(), conn)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", got)
+	assert.Equal(t, []string{"1", "2", "3"}, server.recordedAttempts())
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, slp.get())
+}
+
+func Test_Unary_ShouldNotRetryOtherCodes(t *testing.T) {
+	t.Parallel()
+	server := &echoServer{errs: []error{status.Error(codes.InvalidArgument, "invalid")}}
+	conn := dial(t, server, newInterceptor(&sleepRecorder{}))
+
+	_, err := echo(context.Background(), conn)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"1"}, server.recordedAttempts())
+}
+
+func Test_Unary_ShouldReturnLastErrorWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	server := &echoServer{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+	conn := dial(t, server, newInterceptor(&sleepRecorder{}))
+
+	_, err := echo(context.Background(), conn)
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, []string{"1", "2", "3"}, server.recordedAttempts())
+}
+
+func Test_Unary_ShouldUseCustomRetryCodes(t *testing.T) {
+	t.Parallel()
+	server := &echoServer{errs: []error{status.Error(codes.Aborted, "aborted")}}
+	interceptor := newInterceptor(&sleepRecorder{})
+	interceptor.RetryCodes = []codes.Code{codes.Aborted}
+	conn := dial(t, server, interceptor)
+
+	_, err := echo(context.Background(), conn)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, server.recordedAttempts())
+}
+
+func Test_Unary_ShouldUsePolicyCallOption(t *testing.T) {
+	t.Parallel()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	server := &echoServer{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+	slp := &sleepRecorder{}
+	conn := dial(t, server, newInterceptor(slp))
+	p := retry.Policy().
+		FixedDelay().
+		WithInterval(time.Second).
+		WithMaxAttempts(int64(5)).
+		Build()
+
+	got, err := echo(context.Background(), conn, retrygrpc.WithPolicy(retry.NewPolicyConfig(p)))
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", got)
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, server.recordedAttempts())
+	assert.Equal(t, []time.Duration{time.Second, time.Second, time.Second, time.Second}, slp.get())
+}
+
+func Test_Unary_ShouldNotWaitBeyondDeadline(t *testing.T) {
+	t.Parallel()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	server := &echoServer{errs: []error{unavailable, unavailable, unavailable}}
+	interceptor := newInterceptor(nil)
+	p := retry.Policy().FixedDelay().WithInterval(time.Minute).Build()
+	interceptor.Policy = retry.NewPolicyConfig(p)
+	conn := dial(t, server, interceptor)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+
+	_, err := echo(ctx, conn)
+
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, []string{"1"}, server.recordedAttempts())
+}
+
+func Test_Stream_ShouldRetryServerStreamBeforeFirstMessage(t *testing.T) {
+	t.Parallel()
+	server := &echoServer{errs: []error{
+		status.Error(codes.Unavailable, "unavailable"),
+		status.Error(codes.Unavailable, "unavailable"),
+	}}
+	slp := &sleepRecorder{}
+	conn := dial(t, server, newInterceptor(slp))
+
+	got, err := repeat(context.Background(), conn)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "hello", "hello"}, got)
+	assert.Equal(t, []string{"1", "2", "3"}, server.recordedAttempts())
+	assert.Len(t, slp.get(), 2)
+}
+
+func Test_Stream_ShouldNotRetryOtherCodes(t *testing.T) {
+	t.Parallel()
+	server := &echoServer{errs: []error{status.Error(codes.PermissionDenied, "denied")}}
+	conn := dial(t, server, newInterceptor(&sleepRecorder{}))
+
+	_, err := repeat(context.Background(), conn)
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, []string{"1"}, server.recordedAttempts())
+}
@@ -0,0 +1,141 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryhttp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+// AttemptHeader is the request header carrying the attempt number (starting from 1), set by Transport.
+const AttemptHeader = "Retry-Attempt"
+
+type attemptKey struct{}
+
+// HandlerFunc is http handler returning an error. Errors marked with Transient or Throttled are reported
+// to the clients as retryable.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Transient marks the error as transient, reported to the clients with 503 Service Unavailable status.
+// Transient returns nil if the error is nil.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return RetryableError{Err: err, StatusCode: http.StatusServiceUnavailable}
+}
+
+// Throttled marks the error as caused by throttling, reported to the clients with 429 Too Many Requests status.
+// Throttled returns nil if the error is nil.
+func Throttled(err error) error {
+	if err == nil {
+		return nil
+	}
+	return RetryableError{Err: err, StatusCode: http.StatusTooManyRequests}
+}
+
+// RetryableError is the handler error the clients may retry, see Transient and Throttled.
+type RetryableError struct {
+	Err        error
+	StatusCode int
+}
+
+func (e RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Middleware translates HandlerFunc errors into http responses.
+//
+// Retryable errors are responded with their status code and Retry-After header set to the policy delay
+// after the attempt reported by the client in AttemptHeader. Other errors are responded
+// with 500 Internal Server Error status.
+type Middleware struct {
+	// Policy calculates the Retry-After delays.
+	Policy retry.BackOffPolicy
+	// OnError is notified of every handler error, e.g. to log it.
+	OnError func(r *http.Request, err error)
+}
+
+// NewMiddleware creates Middleware calculating Retry-After delays with the policy.
+func NewMiddleware(p retry.BackOffPolicy) *Middleware {
+	return &Middleware{Policy: p}
+}
+
+// Handle returns http.Handler calling the handler with the attempt number in the request context,
+// see AttemptFromContext.
+func (m *Middleware) Handle(handler HandlerFunc) http.Handler {
+	return WithAttempt(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := handler(w, r)
+		if err == nil {
+			return
+		}
+		if m.OnError != nil {
+			m.OnError(r, err)
+		}
+		var retryableErr RetryableError
+		if !errors.As(err, &retryableErr) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		delay := m.Policy.Delay(AttemptFromContext(r.Context()))
+		w.Header().Set("Retry-After", formatRetryAfter(delay))
+		http.Error(w, http.StatusText(retryableErr.StatusCode), retryableErr.StatusCode)
+	}))
+}
+
+// WithAttempt returns http.Handler passing the attempt number from AttemptHeader in the request context,
+// see AttemptFromContext.
+func WithAttempt(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt, err := strconv.ParseInt(r.Header.Get(AttemptHeader), 10, 64)
+		if err != nil || attempt < 1 {
+			attempt = 1
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), attemptKey{}, attempt)))
+	})
+}
+
+// AttemptFromContext returns the attempt number of the request (starting from 1), passed by WithAttempt.
+// Returns 1 if the attempt number is missing.
+func AttemptFromContext(ctx context.Context) int64 {
+	if attempt, ok := ctx.Value(attemptKey{}).(int64); ok {
+		return attempt
+	}
+	return 1
+}
+
+// formatRetryAfter formats the delay as Retry-After header value, rounded up to whole seconds.
+func formatRetryAfter(delay time.Duration) string {
+	seconds := int64(math.Ceil(delay.Seconds()))
+	return strconv.FormatInt(max(seconds, 1), 10)
+}
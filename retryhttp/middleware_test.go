@@ -0,0 +1,179 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryhttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retryhttp"
+)
+
+func newMiddleware() *retryhttp.Middleware {
+	return retryhttp.NewMiddleware(retry.Policy().
+		BackOff().
+		WithInitialInterval(time.Second).
+		WithMaxInterval(10 * time.Second).
+		Build())
+}
+
+func serve(t *testing.T, handler http.Handler, attempt string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil)
+	if attempt != "" {
+		req.Header.Set(retryhttp.AttemptHeader, attempt)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_Middleware_ShouldRespondServiceUnavailableForTransientErrors(t *testing.T) {
+	t.Parallel()
+	handler := newMiddleware().Handle(func(http.ResponseWriter, *http.Request) error {
+		return retryhttp.Transient(assert.AnError)
+	})
+
+	rec := serve(t, handler, "3")
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "4", rec.Header().Get("Retry-After"))
+}
+
+func Test_Middleware_ShouldRespondTooManyRequestsForThrottledErrors(t *testing.T) {
+	t.Parallel()
+	handler := newMiddleware().Handle(func(http.ResponseWriter, *http.Request) error {
+		return retryhttp.Throttled(assert.AnError)
+	})
+
+	rec := serve(t, handler, "")
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
+
+func Test_Middleware_ShouldCapRetryAfterAtPolicyMaxInterval(t *testing.T) {
+	t.Parallel()
+	handler := newMiddleware().Handle(func(http.ResponseWriter, *http.Request) error {
+		return retryhttp.Transient(assert.AnError)
+	})
+
+	rec := serve(t, handler, "50")
+
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+}
+
+func Test_Middleware_ShouldRespondInternalServerErrorForOtherErrors(t *testing.T) {
+	t.Parallel()
+	var reported error
+	middleware := newMiddleware()
+	middleware.OnError = func(_ *http.Request, err error) {
+		reported = err
+	}
+	handler := middleware.Handle(func(http.ResponseWriter, *http.Request) error {
+		return assert.AnError
+	})
+
+	rec := serve(t, handler, "2")
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+	assert.Equal(t, assert.AnError, reported)
+}
+
+func Test_Middleware_ShouldNotTouchSuccessfulResponses(t *testing.T) {
+	t.Parallel()
+	handler := newMiddleware().Handle(func(w http.ResponseWriter, _ *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	})
+
+	rec := serve(t, handler, "")
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+}
+
+func Test_Middleware_ShouldUnwrapRetryableErrors(t *testing.T) {
+	t.Parallel()
+	err := retryhttp.Transient(assert.AnError)
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, retryhttp.Transient(nil))
+	assert.NoError(t, retryhttp.Throttled(nil))
+}
+
+func Test_AttemptFromContext(t *testing.T) {
+	t.Parallel()
+	tests := map[string]int64{
+		"":        1,
+		"2":       2,
+		"invalid": 1,
+		"-1":      1,
+	}
+	for header, expected := range tests {
+		var got int64
+		handler := retryhttp.WithAttempt(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			got = retryhttp.AttemptFromContext(r.Context())
+		}))
+
+		serve(t, handler, header)
+
+		assert.Equal(t, expected, got, header)
+	}
+	assert.Equal(t, int64(1), retryhttp.AttemptFromContext(context.Background()))
+}
+
+func Test_Middleware_ShouldDriveTransportRetries(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var attempts []int64
+	server := httptest.NewServer(newMiddleware().Handle(func(w http.ResponseWriter, r *http.Request) error {
+		attempt := retryhttp.AttemptFromContext(r.Context())
+		mu.Lock()
+		attempts = append(attempts, attempt)
+		mu.Unlock()
+		if attempt < 3 {
+			return retryhttp.Transient(assert.AnError)
+		}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}))
+	defer server.Close()
+	slp := &sleepRecorder{}
+
+	resp, err := newClient(slp, 3).Get(server.URL)
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []int64{1, 2, 3}, attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, slp.get())
+}
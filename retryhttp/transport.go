@@ -0,0 +1,227 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retryhttp provides net/http integrations of the retry package.
+package retryhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+const maxDrainBytes = 64 << 10
+
+// ErrRequestNotRewindable is returned when the request body cannot be sent again, because the request has no GetBody.
+var ErrRequestNotRewindable = errors.New("request body is not rewindable")
+
+// DefaultRetryStatusCodes are the response status codes retried by default.
+func DefaultRetryStatusCodes() []int {
+	return []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+}
+
+// Transport is http.RoundTripper retrying requests according to the policy.
+//
+// Only idempotent requests are retried - requests with GET, HEAD, OPTIONS, TRACE, PUT and DELETE methods,
+// or with Idempotency-Key header. Requests are retried on connection errors and on the configured
+// response status codes, honouring the Retry-After response header. Request bodies are rewound using
+// http.Request.GetBody. The attempt number is sent in AttemptHeader. Discarded responses are drained and closed.
+//
+// The response of the last attempt is returned, once the attempts run out. If the request context is done
+// before the attempts run out, the context error is returned.
+type Transport struct {
+	// Next is the underlying transport, http.DefaultTransport if nil.
+	Next http.RoundTripper
+	// Policy is the retry policy.
+	Policy retry.PolicyConfig
+	// Sleeper waits between attempts, sleeps until the delay elapses or the request context is done if nil.
+	Sleeper retry.Sleeper
+	// Clock is used to resolve Retry-After dates, system clock if nil.
+	Clock retry.Clock
+	// RetryStatusCodes are the response status codes retried, DefaultRetryStatusCodes if nil.
+	RetryStatusCodes []int
+	// Options are passed to the retry function.
+	Options []retry.Option
+}
+
+// NewTransport creates Transport wrapping the next transport (http.DefaultTransport if nil).
+func NewTransport(next http.RoundTripper, p retry.PolicyConfig) *Transport {
+	return &Transport{
+		Next:   next,
+		Policy: p,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) || !isRewindable(req) {
+		return t.next().RoundTrip(req)
+	}
+
+	attempt := 0
+	var last *http.Response
+	resp, err := retry.Supply(req.Context(), t.sleeper(req.Context()), func() (*http.Response, error) {
+		discard(last)
+		last = nil
+		attempt++
+		attemptReq, err := rewind(req, attempt)
+		if err != nil {
+			return nil, retry.Permanent(err)
+		}
+		resp, err := t.next().RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(t.retryStatusCodes(), resp.StatusCode) {
+			return resp, nil
+		}
+		last = resp
+		statusErr := StatusError{StatusCode: resp.StatusCode}
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.clock().Now()); ok {
+			return resp, retry.RetryAfter(statusErr, delay)
+		}
+		return resp, statusErr
+	}, t.Policy, t.Options...)
+
+	var deadlineErr retry.DeadlineExceededError[*http.Response]
+	if errors.As(err, &deadlineErr) {
+		discard(last)
+		return nil, fmt.Errorf("%w: %w", req.Context().Err(), deadlineErr.Err)
+	}
+	var statusErr StatusError
+	if err != nil && last != nil && errors.As(err, &statusErr) {
+		return last, nil
+	}
+	if err != nil {
+		discard(last)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) next() http.RoundTripper {
+	if t.Next == nil {
+		return http.DefaultTransport
+	}
+	return t.Next
+}
+
+func (t *Transport) sleeper(ctx context.Context) retry.Sleeper {
+	if t.Sleeper == nil {
+		return retry.SleeperF(func(d time.Duration) {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+		})
+	}
+	return t.Sleeper
+}
+
+func (t *Transport) clock() retry.Clock {
+	if t.Clock == nil {
+		return retry.SystemClock()
+	}
+	return t.Clock
+}
+
+func (t *Transport) retryStatusCodes() []int {
+	if t.RetryStatusCodes == nil {
+		return DefaultRetryStatusCodes()
+	}
+	return t.RetryStatusCodes
+}
+
+// StatusError is the error of the attempt which received retryable response status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Retryable response status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return req.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+func isRewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns the request for the given attempt with AttemptHeader set,
+// with the body rewound for the subsequent attempts.
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	attemptReq := req.Clone(req.Context())
+	attemptReq.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+	if attempt == 1 || req.Body == nil || req.Body == http.NoBody {
+		return attemptReq, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRequestNotRewindable, err)
+	}
+	attemptReq.Body = body
+	return attemptReq, nil
+}
+
+// parseRetryAfter parses Retry-After header value, either delay in seconds or HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// discard drains and closes the response body, so the connection may be reused.
+func discard(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	_ = resp.Body.Close()
+}
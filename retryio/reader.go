@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retryio provides io integrations of the retry package.
+package retryio
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/tompaz3/go-retry"
+)
+
+// ErrClosed is returned when reading from the closed Reader or writing to the closed Writer.
+var ErrClosed = errors.New("closed")
+
+// OpenFunc opens the source for reading from the offset, e.g. sends HTTP request with Range header.
+type OpenFunc func(ctx context.Context, offset int64) (io.ReadCloser, error)
+
+// Reader reads from the source opened with OpenFunc. When reading fails mid-stream, Reader reopens the source
+// from the number of bytes already delivered, retrying it according to the policy. The attempts are counted
+// since the last successful read, so any number of failures is survived as long as the reading progresses.
+type Reader struct {
+	rc     io.ReadCloser
+	open   func(offset int64) (io.ReadCloser, error)
+	resume func(supply retry.SupplyFunc[int]) (int, error)
+	offset int64
+	err    error
+}
+
+// NewReader creates Reader of the source opened with OpenFunc, reopened according to the policy.
+// The source is opened lazily, on the first read.
+func NewReader(
+	ctx context.Context, slp retry.Sleeper, open OpenFunc, p retry.PolicyConfig, opts ...retry.Option,
+) *Reader {
+	return &Reader{
+		open: func(offset int64) (io.ReadCloser, error) {
+			return open(ctx, offset)
+		},
+		resume: func(supply retry.SupplyFunc[int]) (int, error) {
+			return retry.Supply(ctx, slp, supply, p, opts...)
+		},
+	}
+}
+
+// Offset returns the number of bytes delivered.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.rc != nil {
+		if n, err := r.read(p); n > 0 || err == nil || errors.Is(err, io.EOF) {
+			return n, err
+		}
+	}
+
+	eof := false
+	n, err := r.resume(func() (int, error) {
+		if r.rc == nil {
+			rc, err := r.open(r.offset)
+			if err != nil {
+				return 0, err
+			}
+			r.rc = rc
+		}
+		n, err := r.read(p)
+		eof = errors.Is(err, io.EOF)
+		if eof {
+			return n, nil
+		}
+		return n, err
+	})
+	if err != nil {
+		var permanentErr retry.PermanentError
+		if errors.As(err, &permanentErr) {
+			err = permanentErr.Err
+		}
+		r.err = err
+		return 0, err
+	}
+	if eof {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// read reads from the opened source, closing it when reading fails. Bytes read before the failure
+// are returned without the error, the source is reopened on the next read.
+func (r *Reader) read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+	if err == nil || errors.Is(err, io.EOF) {
+		return n, err
+	}
+	_ = r.rc.Close()
+	r.rc = nil
+	if n > 0 {
+		return n, nil
+	}
+	return 0, err
+}
+
+// Close closes the opened source.
+func (r *Reader) Close() error {
+	r.err = ErrClosed
+	if r.rc == nil {
+		return nil
+	}
+	err := r.rc.Close()
+	r.rc = nil
+	return err
+}
@@ -0,0 +1,241 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retryio"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultySource is the source injecting faults - failing opens and failing reads after the given number of bytes.
+type faultySource struct {
+	mu         sync.Mutex
+	data       []byte
+	openErrs   []error
+	failAfters []int
+	offsets    []int64
+	closed     int
+}
+
+func (s *faultySource) open(_ context.Context, offset int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets = append(s.offsets, offset)
+	if len(s.openErrs) > 0 {
+		err := s.openErrs[0]
+		s.openErrs = s.openErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	failAfter := -1
+	if len(s.failAfters) > 0 {
+		failAfter = s.failAfters[0]
+		s.failAfters = s.failAfters[1:]
+	}
+	return &faultyReader{source: s, data: s.data[offset:], failAfter: failAfter}, nil
+}
+
+// faultyReader fails once it delivers failAfter bytes, never fails if failAfter is negative.
+type faultyReader struct {
+	source    *faultySource
+	data      []byte
+	failAfter int
+}
+
+func (r *faultyReader) Read(p []byte) (int, error) {
+	if r.failAfter == 0 {
+		return 0, errInjected
+	}
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if r.failAfter > 0 {
+		n = min(n, r.failAfter)
+		r.failAfter -= min(n, len(r.data))
+	}
+	n = copy(p[:n], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *faultyReader) Close() error {
+	r.source.mu.Lock()
+	defer r.source.mu.Unlock()
+	r.source.closed++
+	return nil
+}
+
+type sleepCounter struct {
+	count int
+}
+
+func (s *sleepCounter) Sleep(time.Duration) {
+	s.count++
+}
+
+func newPolicy(maxAttempts int64) retry.PolicyConfig {
+	return retry.NewPolicyConfig(retry.Policy().FixedDelay().WithMaxAttempts(maxAttempts).Build())
+}
+
+func payload(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	return data
+}
+
+func Test_Reader_ShouldResumeFromLastOffsetAfterFaults(t *testing.T) {
+	t.Parallel()
+	data := payload(100)
+	source := &faultySource{data: data, failAfters: []int{10, 15, 0, 30}}
+	slp := &sleepCounter{}
+	r := retryio.NewReader(context.Background(), slp, source.open, newPolicy(3))
+
+	got, err := io.ReadAll(r)
+
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int64(100), r.Offset())
+	assert.Equal(t, []int64{0, 10, 25, 25, 55}, source.offsets)
+	assert.Equal(t, 1, slp.count)
+	require.NoError(t, r.Close())
+	assert.Equal(t, 5, source.closed)
+}
+
+func Test_Reader_ShouldRetryFailingOpens(t *testing.T) {
+	t.Parallel()
+	data := payload(10)
+	source := &faultySource{data: data, openErrs: []error{errInjected, errInjected}}
+	slp := &sleepCounter{}
+	r := retryio.NewReader(context.Background(), slp, source.open, newPolicy(3))
+
+	got, err := io.ReadAll(r)
+
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []int64{0, 0, 0}, source.offsets)
+	assert.Equal(t, 2, slp.count)
+}
+
+func Test_Reader_ShouldSurviveAnyNumberOfFaultsWhileProgressing(t *testing.T) {
+	t.Parallel()
+	data := payload(50)
+	failAfters := make([]int, 0, 25)
+	for range 25 {
+		failAfters = append(failAfters, 2)
+	}
+	source := &faultySource{data: data, failAfters: failAfters}
+	r := retryio.NewReader(context.Background(), &sleepCounter{}, source.open, newPolicy(2))
+
+	got, err := io.ReadAll(r)
+
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Len(t, source.offsets, 26)
+}
+
+func Test_Reader_ShouldReturnErrorWhenReopenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	data := payload(50)
+	source := &faultySource{data: data, failAfters: []int{20, 0, 0, 0}}
+	slp := &sleepCounter{}
+	r := retryio.NewReader(context.Background(), slp, source.open, newPolicy(3))
+
+	got, err := io.ReadAll(r)
+
+	require.ErrorIs(t, err, errInjected)
+	assert.Equal(t, data[:20], got)
+	assert.Equal(t, []int64{0, 20, 20, 20}, source.offsets)
+	assert.Equal(t, 2, slp.count)
+	_, err = r.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, errInjected)
+}
+
+func Test_Reader_ShouldNotRetryPermanentOpenErrors(t *testing.T) {
+	t.Parallel()
+	source := &faultySource{data: payload(10), openErrs: []error{retry.Permanent(errInjected)}}
+	r := retryio.NewReader(context.Background(), &sleepCounter{}, source.open, newPolicy(3))
+
+	_, err := io.ReadAll(r)
+
+	assert.Equal(t, errInjected, err)
+	assert.Len(t, source.offsets, 1)
+}
+
+func Test_Reader_ShouldStopWhenContextIsDone(t *testing.T) {
+	t.Parallel()
+	source := &faultySource{data: payload(10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := retryio.NewReader(ctx, &sleepCounter{}, source.open, newPolicy(3))
+
+	_, err := io.ReadAll(r)
+
+	assert.Error(t, err)
+	assert.Empty(t, source.offsets)
+}
+
+func Test_Reader_ShouldFailReadsAfterClose(t *testing.T) {
+	t.Parallel()
+	source := &faultySource{data: payload(10)}
+	r := retryio.NewReader(context.Background(), &sleepCounter{}, source.open, newPolicy(3))
+	buf := make([]byte, 4)
+	n, err := r.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	require.NoError(t, r.Close())
+	_, err = r.Read(buf)
+
+	assert.ErrorIs(t, err, retryio.ErrClosed)
+	assert.Equal(t, 1, source.closed)
+}
+
+func Test_Reader_ShouldCopyLargeStreams(t *testing.T) {
+	t.Parallel()
+	data := payload(1 << 20)
+	source := &faultySource{data: data, failAfters: []int{100_000, 250_000, 0, 1, 500_000}}
+	r := retryio.NewReader(context.Background(), &sleepCounter{}, source.open, newPolicy(3))
+	var dst bytes.Buffer
+
+	n, err := io.Copy(&dst, r)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, dst.Bytes())
+}
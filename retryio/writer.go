@@ -0,0 +1,125 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryio
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tompaz3/go-retry"
+)
+
+const defaultChunkSize = 5 << 20
+
+// WriteChunkFunc writes the chunk at the offset, e.g. uploads the part of the multipart upload.
+// The chunk must not be retained after the function returns.
+type WriteChunkFunc func(ctx context.Context, offset int64, chunk []byte) error
+
+// Writer buffers the written data into chunks and writes them with WriteChunkFunc,
+// retrying each chunk according to the policy. Checkpoint returns the offset acknowledged so far.
+type Writer struct {
+	write      func(offset int64, chunk []byte) error
+	buf        []byte
+	chunkSize  int
+	checkpoint int64
+	err        error
+}
+
+// NewWriter creates Writer writing the chunks of the given size (5 MiB if not positive)
+// with WriteChunkFunc, retried according to the policy.
+func NewWriter(
+	ctx context.Context, slp retry.Sleeper, write WriteChunkFunc, chunkSize int, p retry.PolicyConfig,
+	opts ...retry.Option,
+) *Writer {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return &Writer{
+		write: func(offset int64, chunk []byte) error {
+			return retry.Run(ctx, slp, func() error {
+				return write(ctx, offset, chunk)
+			}, p, opts...)
+		},
+		buf:       make([]byte, 0, chunkSize),
+		chunkSize: chunkSize,
+	}
+}
+
+// Checkpoint returns the offset acknowledged so far, i.e. the number of bytes written with WriteChunkFunc.
+func (w *Writer) Checkpoint() int64 {
+	return w.checkpoint
+}
+
+// Write implements io.Writer. The data is written once the chunk is full, see Flush.
+// On failure, Write returns the number of bytes of p acknowledged before the failure.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	start := w.checkpoint + int64(len(w.buf))
+	for rest := p; len(rest) > 0; {
+		n := min(len(rest), w.chunkSize-len(w.buf))
+		w.buf = append(w.buf, rest[:n]...)
+		rest = rest[n:]
+		if len(w.buf) < w.chunkSize {
+			continue
+		}
+		if err := w.Flush(); err != nil {
+			return int(min(max(w.checkpoint-start, 0), int64(len(p)))), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the buffered data, even if the chunk is not full.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.write(w.checkpoint, w.buf); err != nil {
+		var permanentErr retry.PermanentError
+		if errors.As(err, &permanentErr) {
+			err = permanentErr.Err
+		}
+		w.err = err
+		return err
+	}
+	w.checkpoint += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close flushes the buffered data and closes the Writer.
+func (w *Writer) Close() error {
+	if errors.Is(w.err, ErrClosed) {
+		return nil
+	}
+	err := w.Flush()
+	if err == nil {
+		w.err = ErrClosed
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryio_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retryio"
+)
+
+type chunk struct {
+	offset int64
+	data   string
+}
+
+// faultySink stores the chunks, failing the writes with the scripted errors.
+type faultySink struct {
+	errs   []error
+	calls  []chunk
+	stored []byte
+}
+
+func (s *faultySink) write(_ context.Context, offset int64, data []byte) error {
+	s.calls = append(s.calls, chunk{offset: offset, data: string(data)})
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	s.stored = append(s.stored[:offset], data...)
+	return nil
+}
+
+func Test_Writer_ShouldWriteChunks(t *testing.T) {
+	t.Parallel()
+	sink := &faultySink{}
+	w := retryio.NewWriter(context.Background(), &sleepCounter{}, sink.write, 4, newPolicy(3))
+
+	n, err := w.Write([]byte("abcdef"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, int64(4), w.Checkpoint())
+	n, err = w.Write([]byte("ghij"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, int64(8), w.Checkpoint())
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, int64(10), w.Checkpoint())
+	assert.Equal(t, "abcdefghij", string(sink.stored))
+	assert.Equal(t, []chunk{{0, "abcd"}, {4, "efgh"}, {8, "ij"}}, sink.calls)
+}
+
+func Test_Writer_ShouldRetryFailingChunks(t *testing.T) {
+	t.Parallel()
+	sink := &faultySink{errs: []error{nil, errInjected, errInjected}}
+	slp := &sleepCounter{}
+	w := retryio.NewWriter(context.Background(), slp, sink.write, 4, newPolicy(3))
+
+	n, err := w.Write([]byte("abcdefghij"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, 10, n)
+	assert.Equal(t, "abcdefghij", string(sink.stored))
+	assert.Equal(t, []chunk{{0, "abcd"}, {4, "efgh"}, {4, "efgh"}, {4, "efgh"}, {8, "ij"}}, sink.calls)
+	assert.Equal(t, 2, slp.count)
+}
+
+func Test_Writer_ShouldReturnAcknowledgedBytesWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	sink := &faultySink{errs: []error{nil, errInjected, errInjected, errInjected}}
+	w := retryio.NewWriter(context.Background(), &sleepCounter{}, sink.write, 4, newPolicy(3))
+	_, err := w.Write([]byte("ab"))
+	require.NoError(t, err)
+
+	n, err := w.Write([]byte("cdefghij"))
+
+	require.ErrorIs(t, err, errInjected)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, int64(4), w.Checkpoint())
+	_, err = w.Write([]byte("k"))
+	assert.ErrorIs(t, err, errInjected)
+	assert.ErrorIs(t, w.Close(), errInjected)
+}
+
+func Test_Writer_ShouldNotRetryPermanentErrors(t *testing.T) {
+	t.Parallel()
+	sink := &faultySink{errs: []error{retry.Permanent(errInjected)}}
+	w := retryio.NewWriter(context.Background(), &sleepCounter{}, sink.write, 4, newPolicy(3))
+
+	n, err := w.Write([]byte("abcd"))
+
+	assert.Equal(t, errInjected, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, sink.calls, 1)
+}
+
+func Test_Writer_ShouldFlushPartialChunk(t *testing.T) {
+	t.Parallel()
+	sink := &faultySink{}
+	w := retryio.NewWriter(context.Background(), &sleepCounter{}, sink.write, 4, newPolicy(3))
+	_, err := w.Write([]byte("ab"))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Flush())
+	_, err = w.Write([]byte("cd"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, int64(4), w.Checkpoint())
+	assert.Equal(t, []chunk{{0, "ab"}, {2, "cd"}}, sink.calls)
+}
+
+func Test_Writer_ShouldFailWritesAfterClose(t *testing.T) {
+	t.Parallel()
+	sink := &faultySink{}
+	w := retryio.NewWriter(context.Background(), &sleepCounter{}, sink.write, 0, newPolicy(3))
+
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+	_, err := w.Write([]byte("a"))
+
+	assert.ErrorIs(t, err, retryio.ErrClosed)
+	assert.Empty(t, sink.calls)
+}
@@ -0,0 +1,399 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retryqueue provides durable retry queue, persisting the jobs in the append-only file,
+// so the retried operations survive process restarts.
+package retryqueue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+const fileMode = 0o600
+
+var (
+	// ErrMissingJobID is returned when enqueuing the job without ID.
+	ErrMissingJobID = errors.New("job ID is missing")
+	// ErrDuplicateJob is returned when enqueuing the job with ID of the pending job.
+	ErrDuplicateJob = errors.New("job is already enqueued")
+	// ErrClosed is returned when using the closed queue.
+	ErrClosed = errors.New("queue is closed")
+)
+
+// Job is the operation retried by the Queue.
+type Job struct {
+	// ID identifies the job.
+	ID string `json:"id"`
+	// Payload is the job data passed to the Handler.
+	Payload []byte `json:"payload,omitempty"`
+	// Policy is the retry policy of the job.
+	Policy retry.PolicyConfig `json:"policy"`
+	// Attempt is the number of the failed attempts.
+	Attempt int64 `json:"attempt"`
+	// NextRunAt is the time of the next attempt.
+	NextRunAt time.Time `json:"nextRunAt"`
+	// LastError is the error message of the last failed attempt.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Handler processes the job. Jobs failing with the permanent errors (see retry.Permanent) are not retried.
+type Handler func(ctx context.Context, job Job) error
+
+type recordType string
+
+const (
+	recordPut    recordType = "put"
+	recordDone   recordType = "done"
+	recordFailed recordType = "failed"
+)
+
+// record is the entry of the write-ahead log - either the job state or the job completion.
+type record struct {
+	Type  recordType `json:"type"`
+	Job   *Job       `json:"job,omitempty"`
+	ID    string     `json:"id,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// Queue is the durable retry queue. Pending jobs and their next run times are persisted in the append-only file
+// (write-ahead log), synced on every change. Open recovers the pending jobs from the file.
+//
+// Jobs are processed at least once - jobs running while the process stops are run again after the restart.
+// Timing of the retries comes from the job policy, see retry.PolicyConfig.Delay.
+type Queue struct {
+	// OnFailure is notified of the jobs which failed permanently or ran out of attempts. Must be set before Run.
+	OnFailure func(job Job, err error)
+
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	clk     retry.Clock
+	jobs    map[string]Job
+	running map[string]bool
+	wake    chan struct{}
+	err     error
+}
+
+// Open opens the queue persisted in the file, creating the file if it does not exist.
+// The incomplete record at the end of the file, left when the process stopped while writing it, is discarded.
+// Clock schedules the jobs, system clock if nil.
+func Open(path string, clk retry.Clock) (*Queue, error) {
+	if clk == nil {
+		clk = retry.SystemClock()
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("opening queue file: %w", err)
+	}
+	jobs, size, err := replay(file)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+	if err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("recovering queue file: %w", err)
+	}
+	return &Queue{
+		path:    path,
+		file:    file,
+		clk:     clk,
+		jobs:    jobs,
+		running: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// replay reads the records, returning the pending jobs and the size of the complete records.
+func replay(r io.Reader) (map[string]Job, int64, error) {
+	jobs := make(map[string]Job)
+	br := bufio.NewReader(r)
+	var size int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return jobs, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var rec record
+		if err = json.Unmarshal(line, &rec); err != nil {
+			return nil, 0, fmt.Errorf("corrupted record at offset %d: %w", size, err)
+		}
+		switch {
+		case rec.Type == recordPut && rec.Job != nil:
+			jobs[rec.Job.ID] = *rec.Job
+		default:
+			delete(jobs, rec.ID)
+		}
+		size += int64(len(line))
+	}
+}
+
+// Enqueue persists the job and schedules it at its NextRunAt time (immediately if zero).
+func (q *Queue) Enqueue(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return ErrClosed
+	}
+	if job.ID == "" {
+		return ErrMissingJobID
+	}
+	if _, ok := q.jobs[job.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, job.ID)
+	}
+	job.Attempt = 0
+	job.LastError = ""
+	if job.NextRunAt.IsZero() {
+		job.NextRunAt = q.clk.Now()
+	}
+	if err := q.append(record{Type: recordPut, Job: &job}); err != nil {
+		return err
+	}
+	q.jobs[job.ID] = job
+	q.notify()
+	return nil
+}
+
+// Pending returns the pending jobs ordered by their next run time.
+func (q *Queue) Pending() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return a.NextRunAt.Compare(b.NextRunAt)
+	})
+	return jobs
+}
+
+// Run processes the due jobs with the handler, using the given number of workers, until the context is canceled.
+// Run waits for the running handlers before returning. Run returns nil once the context is canceled,
+// or the error of writing to the queue file.
+func (q *Queue) Run(ctx context.Context, workers int, handler Handler) error {
+	jobs := make(chan Job)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				q.process(ctx, job, handler)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	for {
+		job, wait, due := q.next()
+		if err := q.failure(); err != nil {
+			return err
+		}
+		if due {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				q.release(job.ID)
+				return nil
+			}
+			continue
+		}
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = q.clk.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.wake:
+		case <-timer:
+		}
+	}
+}
+
+// next returns the earliest due job, marked as running, or the time until the earliest pending job is due
+// (zero if there are no pending jobs).
+func (q *Queue) next() (Job, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var earliest Job
+	found := false
+	for id, job := range q.jobs {
+		if q.running[id] || (found && !job.NextRunAt.Before(earliest.NextRunAt)) {
+			continue
+		}
+		earliest, found = job, true
+	}
+	if !found {
+		return Job{}, 0, false
+	}
+	if wait := earliest.NextRunAt.Sub(q.clk.Now()); wait > 0 {
+		return Job{}, wait, false
+	}
+	q.running[earliest.ID] = true
+	return earliest, 0, true
+}
+
+func (q *Queue) release(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, id)
+}
+
+func (q *Queue) process(ctx context.Context, job Job, handler Handler) {
+	err := handler(ctx, job)
+	if job, failed := q.complete(ctx, job, err); failed && q.OnFailure != nil {
+		q.OnFailure(job, err)
+	}
+}
+
+// complete records the attempt outcome, returns the updated job and true if the job failed.
+func (q *Queue) complete(ctx context.Context, job Job, err error) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.notify()
+	delete(q.running, job.ID)
+	if err != nil && ctx.Err() != nil {
+		// interrupted attempt is run again after the restart
+		return job, false
+	}
+	if err == nil {
+		q.fail(q.append(record{Type: recordDone, ID: job.ID}))
+		delete(q.jobs, job.ID)
+		return job, false
+	}
+
+	job.Attempt++
+	job.LastError = err.Error()
+	var permanentErr retry.PermanentError
+	if errors.As(err, &permanentErr) ||
+		(!job.Policy.IsAttemptingIndefinitely() && job.Attempt >= job.Policy.MaxAttempts()) {
+		q.fail(q.append(record{Type: recordFailed, ID: job.ID, Error: job.LastError}))
+		delete(q.jobs, job.ID)
+		return job, true
+	}
+	job.NextRunAt = q.clk.Now().Add(job.Policy.Delay(job.Attempt))
+	q.fail(q.append(record{Type: recordPut, Job: &job}))
+	q.jobs[job.ID] = job
+	return job, false
+}
+
+func (q *Queue) append(rec record) error {
+	if q.file == nil {
+		return ErrClosed
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding queue record: %w", err)
+	}
+	if _, err = q.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing queue file: %w", err)
+	}
+	if err = q.file.Sync(); err != nil {
+		return fmt.Errorf("syncing queue file: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) fail(err error) {
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+}
+
+func (q *Queue) failure() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Compact rewrites the queue file with the pending jobs only, dropping the history of the completed jobs.
+func (q *Queue) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return ErrClosed
+	}
+	tmp := q.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return fmt.Errorf("compacting queue file: %w", err)
+	}
+	old := q.file
+	q.file = file
+	for _, job := range q.jobs {
+		if err = q.append(record{Type: recordPut, Job: &job}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, q.path)
+	}
+	if err != nil {
+		q.file = old
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("compacting queue file: %w", err)
+	}
+	_ = old.Close()
+	return nil
+}
+
+// Close closes the queue file. Close must not be called while Run is running.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	if err != nil {
+		return fmt.Errorf("closing queue file: %w", err)
+	}
+	return nil
+}
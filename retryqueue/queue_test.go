@@ -0,0 +1,291 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retryqueue_test
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retryqueue"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+func fastPolicy(t *testing.T, attempts string) retry.PolicyConfig {
+	t.Helper()
+	p, err := retry.ParsePolicy("fixed(interval=1ms,attempts=" + attempts + ")")
+	require.NoError(t, err)
+	return p
+}
+
+func open(t *testing.T, path string) *retryqueue.Queue {
+	t.Helper()
+	q, err := retryqueue.Open(path, retry.SystemClock())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = q.Close()
+	})
+	return q
+}
+
+func lines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	count := 0
+	for s := bufio.NewScanner(f); s.Scan(); {
+		count++
+	}
+	return count
+}
+
+// run runs the queue until there are no pending jobs.
+func run(t *testing.T, q *retryqueue.Queue, handler retryqueue.Handler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- q.Run(ctx, 2, handler)
+	}()
+	require.Eventually(t, func() bool {
+		return len(q.Pending()) == 0
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func Test_Queue_ShouldRetryJobsUntilTheySucceed(t *testing.T) {
+	t.Parallel()
+	q := open(t, filepath.Join(t.TempDir(), "queue.wal"))
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a", Payload: []byte("hook"), Policy: fastPolicy(t, "5")}))
+	var mu sync.Mutex
+	var attempts []int64
+
+	run(t, q, func(_ context.Context, job retryqueue.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, job.Attempt)
+		assert.Equal(t, "hook", string(job.Payload))
+		if job.Attempt < 2 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	assert.Equal(t, []int64{0, 1, 2}, attempts)
+}
+
+func Test_Queue_ShouldScheduleRetriesWithPolicyDelays(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	q, err := retryqueue.Open(filepath.Join(t.TempDir(), "queue.wal"), clk)
+	require.NoError(t, err)
+	defer q.Close()
+	p, err := retry.ParsePolicy("backoff(initial=1s,max=1m,attempts=3,coefficient=2)")
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a", Policy: p}))
+	calls := make(chan time.Time, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = q.Run(ctx, 1, func(_ context.Context, job retryqueue.Job) error {
+			calls <- clk.Now()
+			if job.Attempt < 2 {
+				return assert.AnError
+			}
+			return nil
+		})
+	}()
+	start := clk.Now()
+
+	first := <-calls
+	clk.BlockUntil(1)
+	require.Equal(t, start.Add(time.Second), q.Pending()[0].NextRunAt)
+	clk.Advance(time.Second)
+	second := <-calls
+	clk.BlockUntil(1)
+	clk.Advance(2 * time.Second)
+	third := <-calls
+
+	assert.Equal(t, start, first)
+	assert.Equal(t, start.Add(time.Second), second)
+	assert.Equal(t, start.Add(3*time.Second), third)
+}
+
+func Test_Queue_ShouldRecoverPendingJobsOnOpen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := open(t, path)
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a", Policy: fastPolicy(t, "3")}))
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "b", Policy: fastPolicy(t, "3")}))
+	ctx, cancel := context.WithCancel(context.Background())
+	failed := make(chan struct{})
+	go func() {
+		_ = q.Run(ctx, 1, func(_ context.Context, job retryqueue.Job) error {
+			if job.ID == "a" {
+				return nil
+			}
+			close(failed)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-failed
+	cancel()
+	require.Eventually(t, func() bool {
+		return len(q.Pending()) == 1
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, q.Close())
+
+	recovered := open(t, path)
+
+	pending := recovered.Pending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "b", pending[0].ID)
+	assert.Equal(t, int64(0), pending[0].Attempt)
+	got, ok := pending[0].Policy.FixedDelay()
+	require.True(t, ok)
+	assert.Equal(t, int64(3), got.MaxAttempts())
+}
+
+func Test_Queue_ShouldDropJobsWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := open(t, path)
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a", Policy: fastPolicy(t, "2")}))
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "b", Policy: fastPolicy(t, "5")}))
+	var mu sync.Mutex
+	failures := map[string]retryqueue.Job{}
+	q.OnFailure = func(job retryqueue.Job, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.ErrorIs(t, err, assert.AnError)
+		failures[job.ID] = job
+	}
+
+	run(t, q, func(_ context.Context, job retryqueue.Job) error {
+		if job.ID == "b" {
+			return retry.Permanent(assert.AnError)
+		}
+		return assert.AnError
+	})
+	require.NoError(t, q.Close())
+
+	assert.Equal(t, int64(2), failures["a"].Attempt)
+	assert.Equal(t, int64(1), failures["b"].Attempt)
+	assert.Equal(t, assert.AnError.Error(), failures["a"].LastError)
+	assert.Empty(t, open(t, path).Pending())
+}
+
+func Test_Queue_ShouldDiscardIncompleteLastRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := open(t, path)
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a"}))
+	require.NoError(t, q.Close())
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"type":"put","job":{"id":"b"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q = open(t, path)
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "c"}))
+	require.NoError(t, q.Close())
+
+	pending := open(t, path).Pending()
+	require.Len(t, pending, 2)
+	assert.ElementsMatch(t, []string{"a", "c"}, []string{pending[0].ID, pending[1].ID})
+}
+
+func Test_Queue_ShouldRejectCorruptedFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	require.NoError(t, os.WriteFile(path, []byte("not json\n{\"type\":\"done\",\"id\":\"a\"}\n"), 0o600))
+
+	_, err := retryqueue.Open(path, retry.SystemClock())
+
+	assert.ErrorContains(t, err, "corrupted record at offset 0")
+}
+
+func Test_Queue_ShouldRejectInvalidJobs(t *testing.T) {
+	t.Parallel()
+	q := open(t, filepath.Join(t.TempDir(), "queue.wal"))
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a"}))
+
+	assert.ErrorIs(t, q.Enqueue(retryqueue.Job{}), retryqueue.ErrMissingJobID)
+	assert.ErrorIs(t, q.Enqueue(retryqueue.Job{ID: "a"}), retryqueue.ErrDuplicateJob)
+	require.NoError(t, q.Close())
+	assert.ErrorIs(t, q.Enqueue(retryqueue.Job{ID: "b"}), retryqueue.ErrClosed)
+}
+
+func Test_Queue_ShouldCompactFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := open(t, path)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Enqueue(retryqueue.Job{ID: id, Policy: fastPolicy(t, "3")}))
+	}
+	run(t, q, func(context.Context, retryqueue.Job) error {
+		return nil
+	})
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "d"}))
+	require.Equal(t, 7, lines(t, path))
+
+	require.NoError(t, q.Compact())
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "e"}))
+	require.NoError(t, q.Close())
+
+	assert.Equal(t, 2, lines(t, path))
+	pending := open(t, path).Pending()
+	require.Len(t, pending, 2)
+	assert.ElementsMatch(t, []string{"d", "e"}, []string{pending[0].ID, pending[1].ID})
+}
+
+func Test_Queue_ShouldUseSystemClockWhenNil(t *testing.T) {
+	t.Parallel()
+	q, err := retryqueue.Open(filepath.Join(t.TempDir(), "queue.wal"), nil)
+	require.NoError(t, err)
+	defer q.Close()
+	require.NoError(t, q.Enqueue(retryqueue.Job{ID: "a", Policy: fastPolicy(t, "3")}))
+	var calls atomic.Int64
+
+	run(t, q, func(context.Context, retryqueue.Job) error {
+		if calls.Add(1) < 2 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	assert.Equal(t, int64(2), calls.Load())
+}
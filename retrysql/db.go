@@ -0,0 +1,180 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retrysql provides database/sql integrations of the retry package.
+package retrysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+const (
+	// SQLStateSerializationFailure is the SQLSTATE code of the transaction serialization failure.
+	SQLStateSerializationFailure = "40001"
+	// SQLStateDeadlockDetected is the SQLSTATE code of the detected deadlock.
+	SQLStateDeadlockDetected = "40P01"
+)
+
+// Classifier decides whether the database error is retryable.
+type Classifier func(err error) bool
+
+// DefaultClassifier treats broken connections (driver.ErrBadConn), serialization failures and deadlocks
+// as retryable. SQLSTATE codes are read from errors implementing SQLState() string method,
+// as reported by most of the drivers.
+func DefaultClassifier(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return slices.Contains([]string{SQLStateSerializationFailure, SQLStateDeadlockDetected}, stateErr.SQLState())
+	}
+	return false
+}
+
+// DB wraps sql.DB retrying the operations failing with retryable errors according to the policy.
+// Errors not retryable according to the Classifier are returned immediately. If the context is done
+// before the attempts run out, the context error is returned.
+//
+// Only the methods of DB are retried. The wrapped database is exposed as the DB field,
+// the operations called on it directly, e.g. QueryRowContext or PrepareContext, are not retried.
+type DB struct {
+	// DB is the wrapped database.
+	DB *sql.DB
+	// Policy is the retry policy.
+	Policy retry.PolicyConfig
+	// Sleeper waits between attempts, sleeps until the delay elapses or the context is done if nil.
+	Sleeper retry.Sleeper
+	// Classifier decides which errors are retried, DefaultClassifier if nil.
+	Classifier Classifier
+	// Options are passed to the retry function.
+	Options []retry.Option
+}
+
+// NewDB creates DB wrapping the database.
+func NewDB(db *sql.DB, p retry.PolicyConfig) *DB {
+	return &DB{
+		DB:     db,
+		Policy: p,
+	}
+}
+
+// ExecContext executes the query, retrying it on retryable errors.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return supply(ctx, db, func() (sql.Result, error) {
+		return db.DB.ExecContext(ctx, query, args...)
+	})
+}
+
+// QueryContext executes the query returning rows, retrying it on retryable errors.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return supply(ctx, db, func() (*sql.Rows, error) {
+		return db.DB.QueryContext(ctx, query, args...)
+	})
+}
+
+// BeginTx starts the transaction, retrying it on retryable errors.
+// Statements executed within the transaction are not retried, see WithTx.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return supply(ctx, db, func() (*sql.Tx, error) {
+		return db.DB.BeginTx(ctx, opts)
+	})
+}
+
+// TxFunc is the transaction body.
+type TxFunc func(ctx context.Context, tx *sql.Tx) error
+
+// WithTx runs the function within the transaction and commits it. The transaction is rolled back
+// if the function returns an error. The whole transaction is re-run on retryable errors,
+// e.g. serialization failures or deadlocks, so the function must not have side effects outside the transaction.
+func (db *DB) WithTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) error {
+	_, err := supply(ctx, db, func() (any, error) {
+		return nil, runTx(ctx, db.DB, opts, fn)
+	})
+	return err
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn TxFunc) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	if err = fn(ctx, tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+func supply[T any](ctx context.Context, db *DB, op func() (T, error)) (T, error) {
+	classify := db.classifier()
+	res, err := retry.Supply(ctx, db.sleeper(ctx), func() (T, error) {
+		res, err := op()
+		if err != nil && !classify(err) {
+			return res, retry.Permanent(err)
+		}
+		return res, err
+	}, db.Policy, db.Options...)
+	var deadlineErr retry.DeadlineExceededError[T]
+	if errors.As(err, &deadlineErr) {
+		return res, fmt.Errorf("%w: %w", ctx.Err(), deadlineErr.Err)
+	}
+	var permanentErr retry.PermanentError
+	if errors.As(err, &permanentErr) {
+		return res, permanentErr.Err
+	}
+	return res, err
+}
+
+func (db *DB) sleeper(ctx context.Context) retry.Sleeper {
+	if db.Sleeper == nil {
+		return retry.SleeperF(func(d time.Duration) {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+		})
+	}
+	return db.Sleeper
+}
+
+func (db *DB) classifier() Classifier {
+	if db.Classifier == nil {
+		return DefaultClassifier
+	}
+	return db.Classifier
+}
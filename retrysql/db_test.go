@@ -0,0 +1,239 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrysql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retrysql"
+)
+
+var (
+	serializationFailure = sqlStateError{code: retrysql.SQLStateSerializationFailure}
+	deadlockDetected     = sqlStateError{code: retrysql.SQLStateDeadlockDetected}
+	uniqueViolation      = sqlStateError{code: "23505"}
+)
+
+func newDB(d *fakeDatabase) (*retrysql.DB, *sleepCounter) {
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(3)).Build()
+	db := retrysql.NewDB(d.open(), retry.NewPolicyConfig(p))
+	slp := &sleepCounter{}
+	db.Sleeper = slp
+	return db, slp
+}
+
+func Test_DefaultClassifier(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"bad connection":        {err: driver.ErrBadConn, expected: true},
+		"serialization failure": {err: serializationFailure, expected: true},
+		"deadlock detected":     {err: fmt.Errorf("wrapped: %w", deadlockDetected), expected: true},
+		"unique violation":      {err: uniqueViolation, expected: false},
+		"other error":           {err: assert.AnError, expected: false},
+	}
+	for name, test := range tests {
+		assert.Equal(t, test.expected, retrysql.DefaultClassifier(test.err), name)
+	}
+}
+
+func Test_DB_ExecContext_ShouldRetryRetryableErrors(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{execErrs: []error{serializationFailure, deadlockDetected}}
+	db, slp := newDB(d)
+	defer db.DB.Close()
+
+	res, err := db.ExecContext(context.Background(), "UPDATE accounts SET balance = 0")
+
+	require.NoError(t, err)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	_, execs, _, _, _ := d.counts()
+	assert.Equal(t, 3, execs)
+	assert.Equal(t, 2, slp.count())
+}
+
+func Test_DB_ExecContext_ShouldNotRetryOtherErrors(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{execErrs: []error{uniqueViolation}}
+	db, slp := newDB(d)
+	defer db.DB.Close()
+
+	_, err := db.ExecContext(context.Background(), "INSERT INTO accounts VALUES (1)")
+
+	assert.Equal(t, uniqueViolation, err)
+	_, execs, _, _, _ := d.counts()
+	assert.Equal(t, 1, execs)
+	assert.Equal(t, 0, slp.count())
+}
+
+func Test_DB_ExecContext_ShouldUseCustomClassifier(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{execErrs: []error{uniqueViolation, uniqueViolation, uniqueViolation}}
+	db, _ := newDB(d)
+	defer db.DB.Close()
+	db.Classifier = func(err error) bool {
+		return err.Error() == uniqueViolation.Error()
+	}
+
+	_, err := db.ExecContext(context.Background(), "INSERT INTO accounts VALUES (1)")
+
+	assert.ErrorIs(t, err, uniqueViolation)
+	_, execs, _, _, _ := d.counts()
+	assert.Equal(t, 3, execs)
+}
+
+func Test_DB_QueryContext_ShouldRetryRetryableErrors(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{queryErrs: []error{serializationFailure}}
+	db, _ := newDB(d)
+	defer db.DB.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT value FROM answers")
+
+	require.NoError(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	var value int64
+	require.NoError(t, rows.Scan(&value))
+	require.NoError(t, rows.Err())
+	assert.Equal(t, int64(42), value)
+	_, _, queries, _, _ := d.counts()
+	assert.Equal(t, 2, queries)
+}
+
+func Test_DB_BeginTx_ShouldRetryRetryableErrors(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{beginErrs: []error{deadlockDetected}}
+	db, _ := newDB(d)
+	defer db.DB.Close()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	begins, _, _, commits, _ := d.counts()
+	assert.Equal(t, 2, begins)
+	assert.Equal(t, 1, commits)
+}
+
+func Test_DB_WithTx_ShouldRerunTransactionOnRetryableErrors(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{
+		execErrs:   []error{deadlockDetected},
+		commitErrs: []error{serializationFailure},
+	}
+	db, slp := newDB(d)
+	defer db.DB.Close()
+	runs := 0
+
+	err := db.WithTx(context.Background(), nil, func(ctx context.Context, tx *sql.Tx) error {
+		runs++
+		_, err := tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - 10")
+		return err
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, runs)
+	begins, execs, _, commits, rollbacks := d.counts()
+	assert.Equal(t, 3, begins)
+	assert.Equal(t, 3, execs)
+	assert.Equal(t, 2, commits)
+	assert.Equal(t, 1, rollbacks)
+	assert.Equal(t, 2, slp.count())
+}
+
+func Test_DB_WithTx_ShouldRollbackAndReturnNonRetryableErrors(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{}
+	db, slp := newDB(d)
+	defer db.DB.Close()
+	runs := 0
+
+	err := db.WithTx(context.Background(), nil, func(context.Context, *sql.Tx) error {
+		runs++
+		return assert.AnError
+	})
+
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, 1, runs)
+	begins, _, _, commits, rollbacks := d.counts()
+	assert.Equal(t, 1, begins)
+	assert.Equal(t, 0, commits)
+	assert.Equal(t, 1, rollbacks)
+	assert.Equal(t, 0, slp.count())
+}
+
+func Test_DB_WithTx_ShouldReturnLastErrorWhenAttemptsRunOut(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{commitErrs: []error{serializationFailure, serializationFailure, serializationFailure}}
+	db, _ := newDB(d)
+	defer db.DB.Close()
+
+	err := db.WithTx(context.Background(), nil, func(context.Context, *sql.Tx) error {
+		return nil
+	})
+
+	assert.ErrorIs(t, err, serializationFailure)
+	_, _, _, commits, _ := d.counts()
+	assert.Equal(t, 3, commits)
+}
+
+func Test_DB_WithTx_ShouldReturnContextErrorWhenCanceledDuringBackOff(t *testing.T) {
+	t.Parallel()
+	d := &fakeDatabase{commitErrs: []error{serializationFailure, serializationFailure}}
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttempts(int64(3)).Build()
+	db := retrysql.NewDB(d.open(), retry.NewPolicyConfig(p))
+	defer db.DB.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			if _, _, _, commits, _ := d.counts(); commits > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := db.WithTx(ctx, nil, func(context.Context, *sql.Tx) error {
+		return nil
+	})
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, serializationFailure)
+	_, _, _, commits, _ := d.counts()
+	assert.Equal(t, 1, commits)
+}
@@ -0,0 +1,192 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrysql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var errNotSupported = errors.New("not supported")
+
+// sqlStateError is the driver error reporting SQLSTATE code.
+type sqlStateError struct {
+	code string
+}
+
+func (e sqlStateError) Error() string {
+	return "SQLSTATE " + e.code
+}
+
+func (e sqlStateError) SQLState() string {
+	return e.code
+}
+
+// fakeDatabase is database/sql/driver implementation failing the operations with the scripted errors.
+type fakeDatabase struct {
+	mu         sync.Mutex
+	beginErrs  []error
+	execErrs   []error
+	queryErrs  []error
+	commitErrs []error
+	begins     int
+	execs      int
+	queries    int
+	commits    int
+	rollbacks  int
+}
+
+func (d *fakeDatabase) open() *sql.DB {
+	return sql.OpenDB(fakeConnector{d: d})
+}
+
+func (d *fakeDatabase) next(errs *[]error, count *int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	*count++
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func (d *fakeDatabase) counts() (begins, execs, queries, commits, rollbacks int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.begins, d.execs, d.queries, d.commits, d.rollbacks
+}
+
+type fakeConnector struct {
+	d *fakeDatabase
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn(c), nil
+}
+
+func (fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errNotSupported
+}
+
+type fakeConn struct {
+	d *fakeDatabase
+}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errNotSupported
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if err := c.d.next(&c.d.beginErrs, &c.d.begins); err != nil {
+		return nil, err
+	}
+	return fakeTx(c), nil
+}
+
+func (c fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.next(&c.d.execErrs, &c.d.execs); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.next(&c.d.queryErrs, &c.d.queries); err != nil {
+		return nil, err
+	}
+	return &fakeRows{values: []int64{42}}, nil
+}
+
+type fakeTx struct {
+	d *fakeDatabase
+}
+
+func (t fakeTx) Commit() error {
+	return t.d.next(&t.d.commitErrs, &t.d.commits)
+}
+
+func (t fakeTx) Rollback() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.rollbacks++
+	return nil
+}
+
+type fakeRows struct {
+	values []int64
+}
+
+func (*fakeRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (*fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.values[0]
+	r.values = r.values[1:]
+	return nil
+}
+
+type sleepCounter struct {
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (s *sleepCounter) Sleep(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays = append(s.delays, d)
+}
+
+func (s *sleepCounter) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.delays)
+}
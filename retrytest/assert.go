@@ -0,0 +1,54 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrytest
+
+import (
+	"slices"
+	"time"
+)
+
+// TB is the subset of testing.TB used by the assertions.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertDelays asserts the sleeper recorded exactly the delays, in order. Returns whether the assertion passed.
+func AssertDelays(t TB, rec *Sleeper, want ...time.Duration) bool {
+	t.Helper()
+	if got := rec.Delays(); !slices.Equal(got, want) {
+		t.Errorf("Delays not equal:\nexpected: %v\nactual  : %v", want, got)
+		return false
+	}
+	return true
+}
+
+// AssertCalls asserts the supplier was called the number of times. Returns whether the assertion passed.
+func AssertCalls[T any](t TB, s *Supplier[T], want int) bool {
+	t.Helper()
+	if got := s.Calls(); got != want {
+		t.Errorf("Calls not equal:\nexpected: %d\nactual  : %d", want, got)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrytest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retrytest"
+)
+
+// recordingTB records the failures reported by the assertions.
+type recordingTB struct {
+	failures []string
+}
+
+func (*recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func Test_AssertDelays_ShouldPassWhenSleeperRecordedDelays(t *testing.T) {
+	t.Parallel()
+	rec := retrytest.NewSleeper()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(4)).
+		Build()
+
+	_, err := retry.Supply(context.Background(), rec, retrytest.FailTimes(3, assert.AnError, 1).Supply, p)
+
+	assert.NoError(t, err)
+	assert.True(t, retrytest.AssertDelays(t, rec, 100*time.Millisecond, 200*time.Millisecond, 400*time.Millisecond))
+	assert.Equal(t, 700*time.Millisecond, rec.Total())
+}
+
+func Test_AssertDelays_ShouldFailWhenDelaysDiffer(t *testing.T) {
+	t.Parallel()
+	rec := retrytest.NewSleeper()
+	rec.Sleep(time.Second)
+	tb := &recordingTB{}
+
+	ok := retrytest.AssertDelays(tb, rec, time.Second, time.Second)
+
+	assert.False(t, ok)
+	assert.Equal(t, []string{"Delays not equal:\nexpected: [1s 1s]\nactual  : [1s]"}, tb.failures)
+}
+
+func Test_AssertCalls_ShouldFailWhenCallsDiffer(t *testing.T) {
+	t.Parallel()
+	s := retrytest.Script[int]()
+	_, _ = s.Supply()
+	tb := &recordingTB{}
+
+	ok := retrytest.AssertCalls(tb, s, 2)
+
+	assert.False(t, ok)
+	assert.Equal(t, []string{"Calls not equal:\nexpected: 2\nactual  : 1"}, tb.failures)
+}
+
+func Test_Sleeper_ShouldForgetDelaysWhenReset(t *testing.T) {
+	t.Parallel()
+	rec := retrytest.NewSleeper()
+	rec.Sleep(time.Second)
+
+	rec.Reset()
+
+	assert.True(t, retrytest.AssertDelays(t, rec))
+	assert.Zero(t, rec.Total())
+}
@@ -0,0 +1,211 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package chaos provides fault injection for testing retried operations under outage patterns.
+// Injected failures, latencies and errors are driven by the seeded random number generator,
+// so the runs are reproducible.
+package chaos
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/tompaz3/go-retry"
+)
+
+// ErrInjected is the error of the injected failures, unless Injector.Errors are set.
+var ErrInjected = errors.New("injected failure")
+
+// Latency samples the latency added to the call.
+type Latency func(rng *rand.Rand) time.Duration
+
+// Fixed returns latency of the constant duration.
+func Fixed(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// Uniform returns latency uniformly distributed in [lo, hi).
+func Uniform(lo, hi time.Duration) Latency {
+	return func(rng *rand.Rand) time.Duration {
+		if hi <= lo {
+			return lo
+		}
+		return lo + time.Duration(rng.Int64N(int64(hi-lo)))
+	}
+}
+
+// Normal returns normally distributed latency, negative samples are clamped to 0.
+func Normal(mean, stddev time.Duration) Latency {
+	return func(rng *rand.Rand) time.Duration {
+		return max(time.Duration(rng.NormFloat64()*float64(stddev)+float64(mean)), 0)
+	}
+}
+
+// Exponential returns exponentially distributed latency, e.g. the long tail of the slow responses.
+func Exponential(mean time.Duration) Latency {
+	return func(rng *rand.Rand) time.Duration {
+		d := rng.ExpFloat64() * float64(mean)
+		if d >= math.MaxInt64 {
+			return math.MaxInt64
+		}
+		return time.Duration(d)
+	}
+}
+
+// Burst is the scheduled outage - Length consecutive calls failing, starting at the call Start (counted from 0).
+// The burst repeats every Every calls, if Every is positive.
+type Burst struct {
+	Start  int
+	Length int
+	Every  int
+}
+
+func (b Burst) contains(call int) bool {
+	if call < b.Start {
+		return false
+	}
+	offset := call - b.Start
+	if b.Every > 0 {
+		offset %= b.Every
+	}
+	return offset < b.Length
+}
+
+// Injector injects failures and latency into the wrapped operations. Failing calls are either scheduled by Bursts,
+// or drawn with FailureProbability. Injected failures do not call the operation, they return the error drawn
+// from Errors (ErrInjected if empty). Latency, if set, is added to each call using Sleeper.
+//
+// Injector is safe for concurrent use, it should be configured before the first call.
+// The outcomes are reproducible for the given seed and the order of the calls.
+// The zero value is usable, with the random number generator seeded with 0.
+type Injector struct {
+	// FailureProbability is the probability of the failure of each call outside of the bursts, in [0, 1].
+	FailureProbability float64
+	// Latency samples the latency of each call, no latency if nil.
+	Latency Latency
+	// Bursts are the scheduled outages.
+	Bursts []Burst
+	// Errors are the injected errors, drawn uniformly. May include e.g. retry.Permanent or retry.RetryAfter errors.
+	Errors []error
+	// Sleeper waits for the latency, time.Sleep if nil.
+	Sleeper retry.Sleeper
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	calls    int
+	injected int
+}
+
+// New creates Injector with the random number generator seeded with the seed.
+func New(seed uint64) *Injector {
+	return &Injector{
+		rng: newRand(seed),
+	}
+}
+
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // reproducibility, not security
+}
+
+// Supply wraps the operation with the fault injection.
+func Supply[T any](inj *Injector, supply retry.SupplyFunc[T]) retry.SupplyFunc[T] {
+	return func() (T, error) {
+		latency, err := inj.next()
+		if latency > 0 {
+			inj.sleeper().Sleep(latency)
+		}
+		if err != nil {
+			var res T
+			return res, err
+		}
+		return supply()
+	}
+}
+
+// Run wraps the operation returning error only with the fault injection.
+func Run(inj *Injector, run retry.RunFunc) retry.RunFunc {
+	return func() error {
+		_, err := Supply(inj, func() (struct{}, error) {
+			return struct{}{}, run()
+		})()
+		return err
+	}
+}
+
+// Calls returns the number of calls of the wrapped operations.
+func (i *Injector) Calls() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.calls
+}
+
+// Injected returns the number of the injected failures.
+func (i *Injector) Injected() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.injected
+}
+
+// next draws the latency and the injected error (nil if the call should not fail) of the next call.
+func (i *Injector) next() (time.Duration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.rng == nil {
+		i.rng = newRand(0)
+	}
+	call := i.calls
+	i.calls++
+
+	var latency time.Duration
+	if i.Latency != nil {
+		latency = i.Latency(i.rng)
+	}
+	if !i.inBurst(call) && i.rng.Float64() >= i.FailureProbability {
+		return latency, nil
+	}
+	i.injected++
+	if len(i.Errors) == 0 {
+		return latency, ErrInjected
+	}
+	return latency, i.Errors[i.rng.IntN(len(i.Errors))]
+}
+
+func (i *Injector) inBurst(call int) bool {
+	for _, b := range i.Bursts {
+		if b.contains(call) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Injector) sleeper() retry.Sleeper {
+	if i.Sleeper == nil {
+		return retry.SleeperF(time.Sleep)
+	}
+	return i.Sleeper
+}
@@ -0,0 +1,158 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package chaos_test
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retrytest"
+	"github.com/tompaz3/go-retry/retrytest/chaos"
+)
+
+func outcomes(inj *chaos.Injector, calls int) []error {
+	run := chaos.Run(inj, func() error { return nil })
+	errs := make([]error, calls)
+	for i := range errs {
+		errs[i] = run()
+	}
+	return errs
+}
+
+func Test_Injector_ShouldBeReproducibleForSeed(t *testing.T) {
+	t.Parallel()
+	newInjector := func(rec *retrytest.Sleeper) *chaos.Injector {
+		inj := chaos.New(42)
+		inj.FailureProbability = 0.5
+		inj.Latency = chaos.Exponential(10 * time.Millisecond)
+		inj.Sleeper = rec
+		return inj
+	}
+	firstRec, secondRec := retrytest.NewSleeper(), retrytest.NewSleeper()
+	first, second := newInjector(firstRec), newInjector(secondRec)
+
+	assert.Equal(t, outcomes(first, 100), outcomes(second, 100))
+	assert.Equal(t, firstRec.Delays(), secondRec.Delays())
+	assert.Equal(t, first.Injected(), second.Injected())
+	assert.Positive(t, first.Injected())
+	assert.Less(t, first.Injected(), 100)
+}
+
+func Test_Injector_ShouldBeUsableAsZeroValue(t *testing.T) {
+	t.Parallel()
+	zero := &chaos.Injector{FailureProbability: 0.5}
+	seeded := chaos.New(0)
+	seeded.FailureProbability = 0.5
+
+	assert.Equal(t, outcomes(seeded, 100), outcomes(zero, 100))
+	assert.Positive(t, zero.Injected())
+}
+
+func Test_Injector_ShouldFailScheduledBursts(t *testing.T) {
+	t.Parallel()
+	inj := chaos.New(1)
+	inj.Bursts = []chaos.Burst{{Start: 2, Length: 2, Every: 5}}
+
+	errs := outcomes(inj, 10)
+
+	assert.Equal(t, []error{
+		nil, nil, chaos.ErrInjected, chaos.ErrInjected, nil,
+		nil, nil, chaos.ErrInjected, chaos.ErrInjected, nil,
+	}, errs)
+	assert.Equal(t, 10, inj.Calls())
+	assert.Equal(t, 4, inj.Injected())
+}
+
+func Test_Injector_ShouldInjectConfiguredErrors(t *testing.T) {
+	t.Parallel()
+	errUnavailable := errors.New("unavailable")
+	errThrottled := retry.RetryAfter(errors.New("throttled"), time.Second)
+	inj := chaos.New(7)
+	inj.FailureProbability = 1
+	inj.Errors = []error{errUnavailable, errThrottled}
+
+	errs := outcomes(inj, 50)
+
+	assert.Contains(t, errs, errUnavailable)
+	assert.Contains(t, errs, errThrottled)
+	for _, err := range errs {
+		assert.True(t, errors.Is(err, errUnavailable) || errors.Is(err, errThrottled))
+	}
+}
+
+func Test_Supply_ShouldCallOperationWhenNotFailing(t *testing.T) {
+	t.Parallel()
+	rec := retrytest.NewSleeper()
+	inj := chaos.New(1)
+	inj.Latency = chaos.Fixed(5 * time.Millisecond)
+	inj.Sleeper = rec
+	supplier := retrytest.FailTimes(0, nil, "ok")
+
+	res, err := chaos.Supply(inj, supplier.Supply)()
+
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res)
+	retrytest.AssertCalls(t, supplier, 1)
+	retrytest.AssertDelays(t, rec, 5*time.Millisecond)
+}
+
+func Test_Supply_ShouldRetryThroughOutage(t *testing.T) {
+	t.Parallel()
+	inj := chaos.New(3)
+	inj.Bursts = []chaos.Burst{{Start: 0, Length: 3}}
+	inj.Latency = chaos.Uniform(time.Millisecond, 10*time.Millisecond)
+	inj.Sleeper = retrytest.NewSleeper()
+	supplier := retrytest.FailTimes(0, nil, "ok")
+	rec := retrytest.NewSleeper()
+	p := retry.Policy().FixedDelay().WithInterval(time.Second).WithMaxAttempts(int64(4)).Build()
+
+	res, err := retry.Supply(context.Background(), rec, chaos.Supply(inj, supplier.Supply), p)
+
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res)
+	retrytest.AssertCalls(t, supplier, 1)
+	retrytest.AssertDelays(t, rec, time.Second, time.Second, time.Second)
+}
+
+func Test_Latency_ShouldSampleWithinBounds(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewPCG(1, 1)) //nolint:gosec // reproducibility, not security
+	uniform := chaos.Uniform(time.Millisecond, 2*time.Millisecond)
+	normal := chaos.Normal(0, time.Second)
+	exponential := chaos.Exponential(time.Millisecond)
+
+	for range 1000 {
+		d := uniform(rng)
+		assert.GreaterOrEqual(t, d, time.Millisecond)
+		assert.Less(t, d, 2*time.Millisecond)
+		assert.GreaterOrEqual(t, normal(rng), time.Duration(0))
+		assert.GreaterOrEqual(t, exponential(rng), time.Duration(0))
+	}
+	assert.Equal(t, time.Millisecond, chaos.Uniform(time.Millisecond, time.Millisecond)(rng))
+}
@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retrytest provides helpers for testing code using the retry package:
+// the recording Sleeper, scripted suppliers and assertions.
+package retrytest
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Sleeper is retry.Sleeper returning immediately and recording the requested delays.
+// Sleeper is safe for concurrent use.
+type Sleeper struct {
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+// NewSleeper creates the recording Sleeper.
+func NewSleeper() *Sleeper {
+	return &Sleeper{}
+}
+
+// Sleep records the delay and returns immediately.
+func (s *Sleeper) Sleep(duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays = append(s.delays, duration)
+}
+
+// Delays returns the recorded delays, in the order of the calls.
+func (s *Sleeper) Delays() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.delays)
+}
+
+// Total returns the sum of the recorded delays.
+func (s *Sleeper) Total() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total time.Duration
+	for _, d := range s.delays {
+		total += d
+	}
+	return total
+}
+
+// Reset forgets the recorded delays.
+func (s *Sleeper) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays = nil
+}
@@ -0,0 +1,92 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrytest
+
+import (
+	"sync"
+)
+
+// Outcome is the result of the single call of the scripted supplier.
+type Outcome[T any] struct {
+	Result T
+	Err    error
+}
+
+// Succeed returns the successful outcome.
+func Succeed[T any](res T) Outcome[T] {
+	return Outcome[T]{Result: res}
+}
+
+// Fail returns the failed outcome.
+func Fail[T any](err error) Outcome[T] {
+	return Outcome[T]{Err: err}
+}
+
+// Supplier returns the scripted outcomes one by one, repeating the last one once the script runs out,
+// and counts the calls. Supplier is safe for concurrent use.
+type Supplier[T any] struct {
+	mu       sync.Mutex
+	outcomes []Outcome[T]
+	calls    int
+}
+
+// Script creates the supplier returning the outcomes one by one.
+// The supplier without outcomes returns zero value and nil error.
+func Script[T any](outcomes ...Outcome[T]) *Supplier[T] {
+	return &Supplier[T]{outcomes: outcomes}
+}
+
+// FailTimes creates the supplier failing n times with the error and then returning the result.
+func FailTimes[T any](n int, err error, res T) *Supplier[T] {
+	outcomes := make([]Outcome[T], 0, n+1)
+	for range n {
+		outcomes = append(outcomes, Fail[T](err))
+	}
+	return Script(append(outcomes, Succeed(res))...)
+}
+
+// Supply returns the next outcome, it is retry.SupplyFunc.
+func (s *Supplier[T]) Supply() (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.outcomes) == 0 {
+		var res T
+		return res, nil
+	}
+	outcome := s.outcomes[min(s.calls, len(s.outcomes))-1]
+	return outcome.Result, outcome.Err
+}
+
+// Run returns the error of the next outcome, it is retry.RunFunc.
+func (s *Supplier[T]) Run() error {
+	_, err := s.Supply()
+	return err
+}
+
+// Calls returns the number of calls.
+func (s *Supplier[T]) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
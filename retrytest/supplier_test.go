@@ -0,0 +1,80 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retrytest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+	"github.com/tompaz3/go-retry/retrytest"
+)
+
+func Test_FailTimes_ShouldFailThenSucceed(t *testing.T) {
+	t.Parallel()
+	s := retrytest.FailTimes(2, assert.AnError, "done")
+
+	for range 2 {
+		_, err := s.Supply()
+		require.ErrorIs(t, err, assert.AnError)
+	}
+	res, err := s.Supply()
+	require.NoError(t, err)
+	assert.Equal(t, "done", res)
+	assert.Equal(t, 3, s.Calls())
+}
+
+func Test_Script_ShouldRepeatLastOutcome(t *testing.T) {
+	t.Parallel()
+	s := retrytest.Script(retrytest.Succeed(1), retrytest.Fail[int](assert.AnError))
+
+	res, err := s.Supply()
+	require.NoError(t, err)
+	assert.Equal(t, 1, res)
+	require.ErrorIs(t, s.Run(), assert.AnError)
+	require.ErrorIs(t, s.Run(), assert.AnError)
+	assert.Equal(t, 3, s.Calls())
+}
+
+func Test_Script_ShouldSucceedWithoutOutcomes(t *testing.T) {
+	t.Parallel()
+	s := retrytest.Script[int]()
+
+	res, err := s.Supply()
+
+	require.NoError(t, err)
+	assert.Zero(t, res)
+}
+
+func Test_Supplier_ShouldDriveRetryFunctions(t *testing.T) {
+	t.Parallel()
+	s := retrytest.FailTimes(3, assert.AnError, struct{}{})
+	p := retry.Policy().FixedDelay().WithMaxAttempts(int64(5)).Build()
+
+	err := retry.Run(context.Background(), retrytest.NewSleeper(), s.Run, p)
+
+	require.NoError(t, err)
+	retrytest.AssertCalls(t, s, 4)
+}
@@ -0,0 +1,354 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerClosed is returned by the retry loops which were pending or submitted when the scheduler was closed.
+var ErrSchedulerClosed = errors.New("scheduler is closed")
+
+// Scheduler runs retry loops without blocking a goroutine per pending retry.
+// Pending attempts are parked in a single timer heap, and due attempts are dispatched to a bounded worker pool.
+// Scheduler is safe for concurrent use and is meant to be shared by many retry loops.
+type Scheduler struct {
+	clk     Clock
+	due     chan *scheduledAttempt
+	wake    chan struct{}
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	queue  attemptQueue
+	seq    uint64
+	closed bool
+}
+
+// NewScheduler creates the scheduler dispatching attempts to the given number of workers (at least 1).
+// Clock (system clock if nil) is used to park the pending attempts.
+func NewScheduler(clk Clock, workers int) *Scheduler {
+	if clk == nil {
+		clk = SystemClock()
+	}
+	s := &Scheduler{
+		clk:     clk,
+		due:     make(chan *scheduledAttempt),
+		wake:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+	}
+	workers = max(workers, 1)
+	s.wg.Add(workers + 1)
+	go s.dispatch()
+	for range workers {
+		go s.work()
+	}
+	return s
+}
+
+// SupplyScheduled submits the retry loop to the scheduler and returns its handle.
+// Attempts and delays follow the policy and options exactly like Supply, but the loop does not occupy
+// a goroutine while waiting for the next attempt. Canceled loops return DeadlineExceededError as soon as
+// the running attempt (if any) completes, without waiting for the delay.
+func SupplyScheduled[T any](
+	ctx context.Context, s *Scheduler, supply SupplyFunc[T], p policy, opts ...Option,
+) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	f := newFuture[T](cancel)
+	o := newOptions(append(opts[:len(opts):len(opts)], f.tracking(s.clk)))
+	o.limiter = attemptLimiterOf(p)
+	l := &scheduledLoop[T]{
+		supply:       supply,
+		p:            p,
+		o:            o,
+		nextInterval: p.getInitialInterval(),
+		future:       f,
+	}
+	l.letter = l.o.newDeadLetter()
+	a := &scheduledAttempt{
+		index: -1,
+		step: func() (time.Duration, bool) {
+			return l.step(ctx)
+		},
+		abort: l.abort,
+	}
+	l.stopWatch = context.AfterFunc(ctx, func() {
+		s.cancel(a)
+	})
+	s.schedule(a, 0)
+	return f
+}
+
+// RunScheduled submits the retry loop of the function returning error only to the scheduler, see SupplyScheduled.
+func RunScheduled(ctx context.Context, s *Scheduler, run RunFunc, p policy, opts ...Option) *Future[any] {
+	return SupplyScheduled(ctx, s, runFuncToSupplyFunc(run), p, opts...)
+}
+
+// Close stops the scheduler. Close waits for the running attempts, and the pending retry loops
+// return ErrSchedulerClosed joined with the last attempt error.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.closing)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	pending := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+	for _, a := range pending {
+		a.abort(ErrSchedulerClosed)
+	}
+}
+
+// dispatch sends the due attempts to the workers, waiting for the earliest pending attempt in between.
+func (s *Scheduler) dispatch() {
+	defer s.wg.Done()
+	defer close(s.due)
+	for {
+		a, wait := s.next()
+		if a != nil {
+			select {
+			case s.due <- a:
+			case <-s.closing:
+				a.abort(ErrSchedulerClosed)
+				return
+			}
+			continue
+		}
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = s.clk.After(wait)
+		}
+		select {
+		case <-timer:
+		case <-s.wake:
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// next pops the due attempt, or returns the time left until the earliest pending attempt (0 if there are none).
+func (s *Scheduler) next() (*scheduledAttempt, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, 0
+	}
+	if wait := s.queue[0].at.Sub(s.clk.Now()); wait > 0 {
+		return nil, wait
+	}
+	a, _ := heap.Pop(&s.queue).(*scheduledAttempt)
+	return a, 0
+}
+
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+	for a := range s.due {
+		if delay, ok := a.step(); ok {
+			s.schedule(a, delay)
+		}
+	}
+}
+
+// schedule parks the attempt until the delay elapses, or aborts it if the scheduler is closed.
+// Attempts of the canceled retry loops are due immediately.
+func (s *Scheduler) schedule(a *scheduledAttempt, delay time.Duration) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		a.abort(ErrSchedulerClosed)
+		return
+	}
+	if a.canceled {
+		delay = 0
+	}
+	a.at = s.clk.Now().Add(delay)
+	a.seq = s.seq
+	s.seq++
+	heap.Push(&s.queue, a)
+	earliest := s.queue[0] == a
+	s.mu.Unlock()
+	if earliest {
+		s.notify()
+	}
+}
+
+// cancel marks the attempt of the canceled retry loop, making it due immediately, whether parked or not.
+func (s *Scheduler) cancel(a *scheduledAttempt) {
+	s.mu.Lock()
+	a.canceled = true
+	if a.index < 0 || s.closed {
+		s.mu.Unlock()
+		return
+	}
+	a.at = time.Time{}
+	heap.Fix(&s.queue, a.index)
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduledAttempt is the next attempt of the retry loop parked in the scheduler.
+type scheduledAttempt struct {
+	at    time.Time
+	seq   uint64
+	index int
+	// canceled is set once the retry loop is canceled, guarded by Scheduler.mu.
+	canceled bool
+	// step runs the attempt, returns the delay before the next attempt and false if the retry loop is done.
+	step func() (time.Duration, bool)
+	// abort ends the retry loop with the error.
+	abort func(err error)
+}
+
+// attemptQueue is the heap of the parked attempts, ordered by time and then by submission.
+type attemptQueue []*scheduledAttempt
+
+func (q attemptQueue) Len() int {
+	return len(q)
+}
+
+func (q attemptQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q attemptQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *attemptQueue) Push(x any) {
+	a, _ := x.(*scheduledAttempt)
+	a.index = len(*q)
+	*q = append(*q, a)
+}
+
+func (q *attemptQueue) Pop() any {
+	old := *q
+	a := old[len(old)-1]
+	old[len(old)-1] = nil
+	a.index = -1
+	*q = old[:len(old)-1]
+	return a
+}
+
+// scheduledLoop is the state of the retry loop run by the scheduler one attempt at a time, see supplyAttempts.
+type scheduledLoop[T any] struct {
+	supply       SupplyFunc[T]
+	p            policy
+	o            options
+	attempt      int64
+	nextInterval time.Duration
+	reserved     bool
+	res          T
+	err          error
+	letter       *DeadLetter
+	stopWatch    func() bool
+	future       *Future[T]
+}
+
+func (l *scheduledLoop[T]) step(ctx context.Context) (time.Duration, bool) {
+	select {
+	case <-ctx.Done():
+		l.finish(ctx, DeadlineExceededError[T]{Result: l.res, Err: l.err})
+		return 0, false
+	default:
+	}
+
+	if l.o.limiter != nil && !l.reserved {
+		if delay := l.o.limiter.reserve(); delay > 0 {
+			l.reserved = true
+			return delay, true
+		}
+	}
+	l.reserved = false
+
+	l.attempt++
+	l.res, l.err = supplyGuarded(ctx, l.o, l.supply)
+	if l.o.limiter != nil {
+		l.o.limiter.observe(l.err)
+	}
+	if l.err == nil {
+		l.o.onSuccess()
+		l.finish(ctx, nil)
+		return 0, false
+	}
+	l.o.onFailure(l.attempt, l.err)
+	if isPermanent(l.err) || !hasNextAttempt(l.attempt, l.p.getMaxAttempts()) {
+		l.finish(ctx, l.err)
+		return 0, false
+	}
+	if ctx.Err() != nil {
+		l.finish(ctx, DeadlineExceededError[T]{Result: l.res, Err: l.err})
+		return 0, false
+	}
+	if !l.o.allowRetry() {
+		l.finish(ctx, fmt.Errorf("%w: %w", ErrBudgetExhausted, l.err))
+		return 0, false
+	}
+	delay := retryAfter(l.err, l.nextInterval)
+	l.nextInterval = calcNextInterval(l.nextInterval, l.p.getMaxInterval(), l.p.getBackOffCoefficient())
+	l.o.onRetry(RetryEvent{Attempt: l.attempt, Err: l.err, Delay: delay})
+	return delay, true
+}
+
+func (l *scheduledLoop[T]) abort(err error) {
+	if l.err != nil {
+		err = fmt.Errorf("%w: %w", err, l.err)
+	}
+	l.stopWatch()
+	l.future.cancel()
+	l.future.complete(l.res, err)
+}
+
+func (l *scheduledLoop[T]) finish(ctx context.Context, err error) {
+	if err != nil && l.letter != nil {
+		err = l.o.deliverDeadLetter(ctx, l.letter, err)
+	}
+	l.stopWatch()
+	l.future.cancel()
+	l.future.complete(l.res, err)
+}
@@ -0,0 +1,258 @@
+// MIT License
+//
+// Copyright (c) 2024 Tomasz Paździurek
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry_test
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tompaz3/go-retry"
+
+	clock "github.com/jonboulle/clockwork"
+)
+
+func Test_SupplyScheduled_ShouldRespectBackOffPolicy(t *testing.T) {
+	t.Parallel()
+	clk := clock.NewFakeClock()
+	s := retry.NewScheduler(clk, 2)
+	defer s.Close()
+	p := retry.Policy().
+		BackOff().
+		WithInitialInterval(100 * time.Millisecond).
+		WithMaxAttempts(int64(3)).
+		Build()
+	var tryTimes []time.Time
+	supplier := func() (int, error) {
+		tryTimes = append(tryTimes, clk.Now())
+		if len(tryTimes) < 3 {
+			return len(tryTimes), assert.AnError
+		}
+		return len(tryTimes), nil
+	}
+	start := clk.Now()
+
+	f := retry.SupplyScheduled(context.Background(), s, supplier, p)
+	clk.BlockUntil(1)
+	clk.Advance(100 * time.Millisecond)
+	clk.BlockUntil(1)
+	clk.Advance(200 * time.Millisecond)
+
+	res, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, res)
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(100 * time.Millisecond),
+		start.Add(300 * time.Millisecond),
+	}, tryTimes)
+}
+
+func Test_SupplyScheduled_ShouldReturnLastErrorWhenMaxAttemptsReached(t *testing.T) {
+	t.Parallel()
+	s := retry.NewScheduler(retry.SystemClock(), 2)
+	defer s.Close()
+	p := retry.Policy().FixedDelay().WithInterval(time.Millisecond).WithMaxAttempts(int64(3)).Build()
+	var calls atomic.Int64
+	supplier := func() (int64, error) {
+		return calls.Add(1), assert.AnError
+	}
+
+	res, err := retry.SupplyScheduled(context.Background(), s, supplier, p).Wait(context.Background())
+
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, int64(3), res)
+}
+
+func Test_SupplyScheduled_ShouldLimitConcurrentAttemptsToWorkers(t *testing.T) {
+	t.Parallel()
+	s := retry.NewScheduler(retry.SystemClock(), 2)
+	defer s.Close()
+	p := retry.Policy().FixedDelay().WithInterval(time.Millisecond).WithMaxAttempts(int64(2)).Build()
+	var running, maxRunning atomic.Int64
+	run := func() error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	futures := make([]*retry.Future[any], 10)
+	for i := range futures {
+		futures[i] = retry.RunScheduled(context.Background(), s, run, p)
+	}
+
+	for _, f := range futures {
+		_, err := f.Wait(context.Background())
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, maxRunning.Load(), int64(2))
+}
+
+func Test_SupplyScheduled_ShouldStopParkedRetryWhenCanceled(t *testing.T) {
+	t.Parallel()
+	s := retry.NewScheduler(retry.SystemClock(), 1)
+	defer s.Close()
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttemptsIndefinite().Build()
+	retried := make(chan struct{})
+	supplier := func() (int, error) { return 1, assert.AnError }
+
+	f := retry.SupplyScheduled(context.Background(), s, supplier, p,
+		retry.WithRetryListener(func(retry.RetryEvent) { close(retried) }))
+	<-retried
+	f.Cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := f.Wait(ctx)
+	assert.Equal(t, retry.DeadlineExceededError[int]{Result: 1, Err: assert.AnError}, err)
+	assert.Equal(t, 1, res)
+}
+
+func Test_SupplyScheduled_ShouldStopRetryCanceledBeforeParking(t *testing.T) {
+	t.Parallel()
+	s := retry.NewScheduler(clock.NewFakeClock(), 1)
+	defer s.Close()
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttemptsIndefinite().Build()
+	supplier := func() (int, error) { return 1, assert.AnError }
+	ctx, cancelLoop := context.WithCancel(context.Background())
+
+	f := retry.SupplyScheduled(ctx, s, supplier, p, retry.WithRetryListener(func(retry.RetryEvent) {
+		cancelLoop()
+		// let the cancellation be observed before the next attempt is parked
+		time.Sleep(50 * time.Millisecond)
+	}))
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := f.Wait(waitCtx)
+	assert.Equal(t, retry.DeadlineExceededError[int]{Result: 1, Err: assert.AnError}, err)
+	assert.Equal(t, 1, res)
+}
+
+func Test_SupplyScheduled_ShouldStopRunningAttemptWhenCanceled(t *testing.T) {
+	t.Parallel()
+	s := retry.NewScheduler(retry.SystemClock(), 1)
+	defer s.Close()
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttemptsIndefinite().Build()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	supplier := func() (int, error) {
+		close(started)
+		<-release
+		return 1, assert.AnError
+	}
+
+	f := retry.SupplyScheduled(context.Background(), s, supplier, p)
+	<-started
+	f.Cancel()
+	// let the cancellation be observed while the attempt is still running
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := f.Wait(ctx)
+	assert.Equal(t, retry.DeadlineExceededError[int]{Result: 1, Err: assert.AnError}, err)
+	assert.Equal(t, 1, res)
+}
+
+func Test_SupplyScheduled_ShouldFailPendingRetriesWhenClosed(t *testing.T) {
+	t.Parallel()
+	s := retry.NewScheduler(retry.SystemClock(), 1)
+	p := retry.Policy().FixedDelay().WithInterval(time.Hour).WithMaxAttemptsIndefinite().Build()
+	retried := make(chan struct{})
+	supplier := func() (int, error) { return 1, assert.AnError }
+
+	f := retry.SupplyScheduled(context.Background(), s, supplier, p,
+		retry.WithRetryListener(func(retry.RetryEvent) { close(retried) }))
+	<-retried
+	s.Close()
+
+	_, err := f.Wait(context.Background())
+	require.ErrorIs(t, err, retry.ErrSchedulerClosed)
+	require.ErrorIs(t, err, assert.AnError)
+
+	_, err = retry.SupplyScheduled(context.Background(), s, supplier, p).Wait(context.Background())
+	require.ErrorIs(t, err, retry.ErrSchedulerClosed)
+}
+
+const benchmarkOperations = 1000
+
+func flakySupplier() retry.SupplyFunc[int] {
+	var calls atomic.Int64
+	return func() (int, error) {
+		if calls.Add(1) < 3 {
+			return 0, assert.AnError
+		}
+		return 1, nil
+	}
+}
+
+func Benchmark_Supply(b *testing.B) {
+	p := retry.Policy().FixedDelay().WithInterval(time.Millisecond).WithMaxAttempts(int64(3)).Build()
+	slp := retry.SleeperF(time.Sleep)
+	var goroutines int
+	for range b.N {
+		var wg sync.WaitGroup
+		wg.Add(benchmarkOperations)
+		for range benchmarkOperations {
+			go func() {
+				defer wg.Done()
+				_, _ = retry.Supply(context.Background(), slp, flakySupplier(), p)
+			}()
+		}
+		goroutines = max(goroutines, runtime.NumGoroutine())
+		wg.Wait()
+	}
+	b.ReportMetric(float64(goroutines), "goroutines")
+}
+
+func Benchmark_SupplyScheduled(b *testing.B) {
+	p := retry.Policy().FixedDelay().WithInterval(time.Millisecond).WithMaxAttempts(int64(3)).Build()
+	s := retry.NewScheduler(retry.SystemClock(), runtime.GOMAXPROCS(0))
+	defer s.Close()
+	var goroutines int
+	for range b.N {
+		futures := make([]*retry.Future[int], benchmarkOperations)
+		for i := range futures {
+			futures[i] = retry.SupplyScheduled(context.Background(), s, flakySupplier(), p)
+		}
+		goroutines = max(goroutines, runtime.NumGoroutine())
+		for _, f := range futures {
+			_, _ = f.Wait(context.Background())
+		}
+	}
+	b.ReportMetric(float64(goroutines), "goroutines")
+}